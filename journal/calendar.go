@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/lorossi/colorize"
+)
+
+// characters used for the intensity levels, from none to highest
+var intensityShades = []string{"·", "░", "▒", "▓", "█"}
+
+// colors used for the intensity levels, from none to highest
+var intensityColors = []colorize.Style{colorize.FgBrightBlack, colorize.FgGreen, colorize.FgGreen, colorize.FgBrightGreen, colorize.FgBrightGreen}
+
+// returns the value of a day: the number of entries or, if a field
+// is provided, the sum of its numeric values
+func dayValue(entries []Entry, day time.Time, field string) (value float64) {
+	for _, entry := range entries {
+		if !sameDay(entry.timeObj, day) {
+			continue
+		}
+		if field == "" {
+			value++
+		} else if number, ok := parseNumber(entry.Fields[field]); ok {
+			value += number
+		}
+	}
+	return value
+}
+
+// returns the intensity level (0 to 4) of a value, relative to the maximum
+func intensityLevel(value, max float64) int {
+	if value <= 0 || max <= 0 {
+		return 0
+	}
+	level := int(math.Ceil(value / max * float64(len(intensityShades)-1)))
+	if level >= len(intensityShades) {
+		level = len(intensityShades) - 1
+	}
+	return level
+}
+
+// colors a text according to the intensity level
+func colorIntensity(text string, level int) string {
+	return colorText(text, intensityColors[level])
+}
+
+// returns the days of the period starting at start, with their values
+func periodValues(entries []Entry, start time.Time, period string, field string) (days []time.Time, values []float64, max float64) {
+	end := nextPeriod(start, period)
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		value := dayValue(entries, day, field)
+		days = append(days, day)
+		values = append(values, value)
+		if value > max {
+			max = value
+		}
+	}
+	return days, values, max
+}
+
+// print the legend of the intensity levels
+func printIntensityLegend(field string, max float64) {
+	fmt.Print("Less ")
+	for level, shade := range intensityShades {
+		fmt.Print(colorIntensity(shade, level), " ")
+	}
+	fmt.Print("More")
+	if field != "" {
+		fmt.Print(" (@", field, ", max ", formatNumber(max), " in a day)")
+	} else {
+		fmt.Print(" (max ", formatNumber(max), " entries in a day)")
+	}
+	fmt.Print("\n\n")
+}
+
+// print a month as a grid, marking the days with entries
+func printMonthCalendar(entries []Entry, month time.Time, field string) {
+	// only keep the entries of the month
+	var monthEntries []Entry
+	for _, entry := range entries {
+		if sameMonth(entry.timeObj, month) {
+			monthEntries = append(monthEntries, entry)
+		}
+	}
+
+	days, values, max := periodValues(monthEntries, month, "month", field)
+
+	// print header
+	title := month.Format("January 2006")
+	fmt.Print("\n", strings.Repeat(" ", (28-len(title))/2), colorText(title, colorize.FgBrightBlue), "\n")
+	fmt.Print(colorText(" Mo  Tu  We  Th  Fr  Sa  Su", colorize.FgBrightGreen), "\n")
+
+	// weeks start on monday
+	offset := (int(month.Weekday()) + 6) % 7
+	fmt.Print(strings.Repeat("    ", offset))
+
+	for i, day := range days {
+		level := intensityLevel(values[i], max)
+		marker := " "
+		if level > 0 {
+			marker = intensityShades[level]
+		}
+		fmt.Print(colorIntensity(fmt.Sprintf("%3d%s", day.Day(), marker), level))
+
+		if (offset+i+1)%7 == 0 {
+			fmt.Println()
+		}
+	}
+	// end the last week, if incomplete
+	if (offset+len(days))%7 != 0 {
+		fmt.Println()
+	}
+	fmt.Println()
+	printIntensityLegend(field, max)
+}
+
+// print a year as a heatmap, with one column for each week
+func printYearCalendar(entries []Entry, year time.Time, field string) {
+	// only keep the entries of the year
+	var yearEntries []Entry
+	for _, entry := range entries {
+		if sameYear(entry.timeObj, year) {
+			yearEntries = append(yearEntries, entry)
+		}
+	}
+
+	days, values, max := periodValues(yearEntries, year, "year", field)
+	// weeks start on monday
+	offset := (int(year.Weekday()) + 6) % 7
+	weeks := (offset + len(days) + 6) / 7
+
+	// print header with the month names above their first week
+	fmt.Print("\n    ", colorText(year.Format("2006"), colorize.FgBrightBlue), "\n")
+	header := []rune(strings.Repeat(" ", weeks+3))
+	for i, day := range days {
+		if day.Day() == 1 {
+			copy(header[(offset+i)/7:], []rune(day.Format("Jan")))
+		}
+	}
+	fmt.Print("    ", colorText(strings.TrimRight(string(header), " "), colorize.FgBrightGreen), "\n")
+
+	// print one row for each weekday
+	weekdays := []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+	for row, name := range weekdays {
+		fmt.Print(colorText(name, colorize.FgBrightGreen), " ")
+		for week := 0; week < weeks; week++ {
+			i := week*7 + row - offset
+			if i < 0 || i >= len(days) {
+				fmt.Print(" ")
+				continue
+			}
+			level := intensityLevel(values[i], max)
+			fmt.Print(colorIntensity(intensityShades[level], level))
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+	printIntensityLegend(field, max)
+}
+
+// print a calendar of the month or year described by a string
+// (today, yesterday, weekday, YYYY-MM-DD, YYYY-MM or YYYY)
+func printCalendar(entries []Entry, period string, field string) (e error) {
+	date, level := parseDay(period)
+	field = strings.TrimPrefix(field, "@")
+
+	switch level {
+	case 0, 1, 2:
+		month, _ := periodStart(date, "month")
+		printMonthCalendar(entries, month, field)
+	case 3:
+		year, _ := periodStart(date, "year")
+		printYearCalendar(entries, year, field)
+	default:
+		return errors.New("date was not provided correctly. Format: YYYY-MM or YYYY")
+	}
+
+	return nil
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lorossi/colorize"
+	"golang.org/x/term"
+)
+
+// height of line charts, in rows
+const lineChartHeight = 10
+
+// characters used in sparklines, from lowest to highest
+var sparklineTicks = []rune("▁▂▃▄▅▆▇█")
+
+// single value of a chart
+type chartPoint struct {
+	label string
+	value float64
+	// true if there are no values in the period
+	missing bool
+}
+
+// returns the width of the terminal, in characters
+func terminalWidth() int {
+	if width, _, e := term.GetSize(int(os.Stdout.Fd())); e == nil && width > 0 {
+		return width
+	}
+	if width, e := strconv.Atoi(os.Getenv("COLUMNS")); e == nil && width > 0 {
+		return width
+	}
+	return 80
+}
+
+// returns the value of a period according to the aggregation
+func aggregateValue(p fieldPeriod, aggregation string) (value float64, ok bool) {
+	if aggregation == "count" {
+		return float64(p.count), true
+	}
+	if p.numbers == 0 {
+		return 0, false
+	}
+
+	switch aggregation {
+	case "sum":
+		return p.sum, true
+	case "min":
+		return p.min, true
+	case "max":
+		return p.max, true
+	}
+	return p.mean(), true
+}
+
+// returns the points of the chart of a field, one for each period
+// between the first and the last value. Periods without values are missing
+func chartPoints(entries []Entry, key, period, aggregation string) (points []chartPoint, e error) {
+	switch aggregation {
+	case "sum", "mean", "min", "max", "count":
+	default:
+		return nil, errors.New("unknown aggregation '" + aggregation + "'. Aggregations: sum, mean, min, max, count")
+	}
+
+	periods, e := aggregateField(entries, key, period)
+	if e != nil {
+		return nil, e
+	}
+
+	byStart := make(map[time.Time]fieldPeriod)
+	for _, p := range periods {
+		byStart[p.start] = p
+	}
+
+	last := periods[len(periods)-1].start
+	for start := periods[0].start; !start.After(last); start = nextPeriod(start, period) {
+		point := chartPoint{label: periodLabel(start, period), missing: true}
+		if p, ok := byStart[start]; ok {
+			point.value, ok = aggregateValue(p, aggregation)
+			// a sum can overflow
+			point.missing = !ok || math.IsInf(point.value, 0) || math.IsNaN(point.value)
+		}
+		points = append(points, point)
+	}
+
+	if aggregation != "count" {
+		numeric := false
+		for _, p := range points {
+			numeric = numeric || !p.missing
+		}
+		if !numeric {
+			return nil, errors.New("the field has no numeric values")
+		}
+	}
+
+	return points, nil
+}
+
+// returns the minimum and the maximum value of the points
+func pointsRange(points []chartPoint) (min, max float64) {
+	first := true
+	for _, p := range points {
+		if p.missing {
+			continue
+		}
+		if first || p.value < min {
+			min = p.value
+		}
+		if first || p.value > max {
+			max = p.value
+		}
+		first = false
+	}
+	return min, max
+}
+
+// returns the value limited to the range between low and high
+func clamp(value, low, high int) int {
+	if value < low {
+		return low
+	}
+	if value > high {
+		return high
+	}
+	return value
+}
+
+// keep the most recent points that fit in the width
+func fitPoints(points []chartPoint, width int) []chartPoint {
+	if width < 1 {
+		width = 1
+	}
+	if len(points) > width {
+		return points[len(points)-width:]
+	}
+	return points
+}
+
+// print the values of the points as a single line
+func printSparkline(points []chartPoint, width int) {
+	points = fitPoints(points, width)
+	min, max := pointsRange(points)
+
+	var line strings.Builder
+	for _, p := range points {
+		if p.missing {
+			line.WriteRune(' ')
+			continue
+		}
+		tick := 0
+		if max > min {
+			tick = int((p.value - min) / (max - min) * float64(len(sparklineTicks)-1))
+		}
+		line.WriteRune(sparklineTicks[clamp(tick, 0, len(sparklineTicks)-1)])
+	}
+
+	fmt.Println(colorText(line.String(), colorize.FgBrightGreen))
+	fmt.Print(points[0].label, " to ", points[len(points)-1].label)
+	fmt.Print(", min ", formatNumber(min), " max ", formatNumber(max), "\n")
+}
+
+// print one horizontal bar for each point
+func printBarChart(points []chartPoint, width int) {
+	_, max := pointsRange(points)
+
+	// width of the labels and of the values
+	labelWidth, valueWidth := 0, 0
+	for _, p := range points {
+		if len(p.label) > labelWidth {
+			labelWidth = len(p.label)
+		}
+		if len(formatNumber(p.value)) > valueWidth {
+			valueWidth = len(formatNumber(p.value))
+		}
+	}
+	barWidth := width - labelWidth - valueWidth - 3
+	if barWidth < 1 {
+		barWidth = 1
+	}
+
+	for _, p := range points {
+		fmt.Print(colorText(fmt.Sprintf("%-*s", labelWidth, p.label), colorize.FgBrightBlue), " ")
+		if p.missing {
+			fmt.Println()
+			continue
+		}
+		length := 0
+		if max > 0 && p.value > 0 {
+			length = int(p.value / max * float64(barWidth))
+		}
+		length = clamp(length, 0, barWidth)
+		fmt.Print(colorText(strings.Repeat("█", length), colorize.FgBrightGreen), " ", formatNumber(p.value), "\n")
+	}
+}
+
+// check if a row is strictly between two other rows
+func between(row, a, b int) bool {
+	if a > b {
+		a, b = b, a
+	}
+	return row > a && row < b
+}
+
+// print the points as a line, with the values on the vertical axis
+func printLineChart(points []chartPoint, width int) {
+	min, max := pointsRange(points)
+	axisWidth := len(formatNumber(max))
+	if len(formatNumber(min)) > axisWidth {
+		axisWidth = len(formatNumber(min))
+	}
+	points = fitPoints(points, width-axisWidth-2)
+
+	// row of each point, from 0 (bottom) to lineChartHeight - 1 (top)
+	rows := make([]int, len(points))
+	for i, p := range points {
+		if max > min {
+			rows[i] = clamp(int((p.value-min)/(max-min)*float64(lineChartHeight-1)), 0, lineChartHeight-1)
+		}
+	}
+
+	for row := lineChartHeight - 1; row >= 0; row-- {
+		// print the vertical axis
+		label := ""
+		if row == lineChartHeight-1 {
+			label = formatNumber(max)
+		} else if row == 0 {
+			label = formatNumber(min)
+		}
+		fmt.Print(colorText(fmt.Sprintf("%*s", axisWidth, label), colorize.FgBrightBlue), " │")
+
+		var line strings.Builder
+		previous := -1
+		for i, p := range points {
+			switch {
+			case p.missing:
+				line.WriteRune(' ')
+			case rows[i] == row:
+				line.WriteRune('●')
+			case previous != -1 && between(row, previous, rows[i]):
+				// connect to the previous point
+				line.WriteRune('│')
+			default:
+				line.WriteRune(' ')
+			}
+			if !p.missing {
+				previous = rows[i]
+			} else {
+				previous = -1
+			}
+		}
+		fmt.Println(colorText(line.String(), colorize.FgBrightGreen))
+	}
+
+	// print the horizontal axis
+	fmt.Print(strings.Repeat(" ", axisWidth), " └", strings.Repeat("─", len(points)), "\n")
+	fmt.Print(strings.Repeat(" ", axisWidth+2), points[0].label)
+	if len(points) > 1 {
+		last := points[len(points)-1].label
+		if gap := len(points) - len(points[0].label) - len(last); gap > 0 {
+			fmt.Print(strings.Repeat(" ", gap), last)
+		} else {
+			fmt.Print(" to ", last)
+		}
+	}
+	fmt.Print("\n")
+}
+
+// print a chart of a field
+// types: bar, line, sparkline
+func printChart(points []chartPoint, key, style string) (e error) {
+	width := terminalWidth()
+
+	var render func(points []chartPoint, width int)
+	switch style {
+	case "bar":
+		render = printBarChart
+	case "line":
+		render = printLineChart
+	case "sparkline":
+		render = printSparkline
+	default:
+		return errors.New("unknown chart type '" + style + "'. Types: bar, line, sparkline")
+	}
+
+	fmt.Print("\n", colorText("@"+strings.TrimPrefix(key, "@"), colorize.FgBrightMagenta), "\n")
+	render(points, width)
+	fmt.Println()
+
+	return nil
+}
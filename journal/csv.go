@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// columns of a CSV export, before the ones of the fields
+var csvColumns = []string{"id", "timestamp", "title", "content", "tags"}
+
+// returns the entries as CSV, with one column for each field key.
+// Tags are joined by spaces
+func csvEntries(entries []Entry, comma rune) ([]byte, error) {
+	// union of the field keys
+	keys := make(map[string]bool)
+	for _, entry := range entries {
+		for k := range entry.Fields {
+			keys[k] = true
+		}
+	}
+	var fieldKeys []string
+	for k := range keys {
+		fieldKeys = append(fieldKeys, k)
+	}
+	sort.Strings(fieldKeys)
+
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	w.Comma = comma
+
+	header := append([]string{}, csvColumns...)
+	for _, k := range fieldKeys {
+		header = append(header, "@"+k)
+	}
+	w.Write(header)
+
+	for _, entry := range sortedEntries(entries) {
+		row := []string{entry.ID, entry.Timestamp, entry.Title, entry.Content, strings.Join(entry.Tags, " ")}
+		for _, k := range fieldKeys {
+			row = append(row, entry.Fields[k])
+		}
+		w.Write(row)
+	}
+
+	w.Flush()
+	return b.Bytes(), w.Error()
+}
+
+// returns where the value of a column goes: id, timestamp, title,
+// content, tags, @key for fields or - to ignore it
+func defaultColumnTarget(column string) string {
+	switch strings.ToLower(strings.TrimSpace(column)) {
+	case "id":
+		return "id"
+	case "timestamp", "date", "time":
+		return "timestamp"
+	case "title":
+		return "title"
+	case "content", "text", "body":
+		return "content"
+	case "tags":
+		return "tags"
+	}
+	return "@" + strings.TrimPrefix(strings.TrimSpace(column), "@")
+}
+
+// parses a column mapping in format column=target,column=target
+// (e.g. Date=timestamp,Notes=content,Mood=@mood,Junk=-)
+func parseColumnMapping(mapping string) (targets map[string]string, e error) {
+	targets = make(map[string]string)
+	if strings.TrimSpace(mapping) == "" {
+		return targets, nil
+	}
+
+	for _, pair := range strings.Split(mapping, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, errors.New("cannot parse column mapping '" + pair + "'. Format: column=target")
+		}
+
+		target := strings.TrimSpace(parts[1])
+		switch {
+		case target == "id", target == "timestamp", target == "title", target == "content", target == "tags", target == "-":
+		case strings.HasPrefix(target, "@") && len(target) > 1:
+		default:
+			return nil, errors.New("unknown column target '" + target + "'. Targets: id, timestamp, title, content, tags, @field, -")
+		}
+		targets[strings.TrimSpace(parts[0])] = target
+	}
+	return targets, nil
+}
+
+// reads the entries from a CSV file. The first row contains the names
+// of the columns, mapped to the entries with the mapping
+func parseCSVEntries(data []byte, comma rune, mapping string) (entries []Entry, e error) {
+	targets, e := parseColumnMapping(mapping)
+	if e != nil {
+		return nil, e
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	rows, e := r.ReadAll()
+	if e != nil {
+		return nil, errors.New("cannot parse file: " + e.Error())
+	}
+	if len(rows) < 2 {
+		return nil, errors.New("no entries found in file")
+	}
+
+	// find the target of each column
+	header := rows[0]
+	columns := make([]string, len(header))
+	for i, name := range header {
+		if target, ok := targets[name]; ok {
+			columns[i] = target
+			delete(targets, name)
+		} else {
+			columns[i] = defaultColumnTarget(name)
+		}
+	}
+	if len(targets) > 0 {
+		var missing []string
+		for name := range targets {
+			missing = append(missing, name)
+		}
+		sort.Strings(missing)
+		return nil, errors.New("columns not found in file: " + strings.Join(missing, ", "))
+	}
+
+	hasTimestamp := false
+	for _, c := range columns {
+		hasTimestamp = hasTimestamp || c == "timestamp"
+	}
+	if !hasTimestamp {
+		return nil, errors.New("no timestamp column found. Map one with --columns name=timestamp")
+	}
+
+	for line, row := range rows[1:] {
+		entry := Entry{Fields: make(map[string]string)}
+		// short rows might not reach the timestamp column
+		dated := false
+		for i, value := range row {
+			if i >= len(columns) {
+				break
+			}
+			value = strings.TrimSpace(value)
+
+			switch target := columns[i]; target {
+			case "id":
+				entry.ID = value
+			case "timestamp":
+				if value == "" {
+					break
+				}
+				entry.timeObj, e = parseTimestamp(value)
+				dated = true
+				if e != nil {
+					return nil, errors.New("line " + strconv.Itoa(line+2) + ": " + e.Error())
+				}
+			case "title":
+				entry.Title = value
+			case "content":
+				entry.Content = value
+			case "tags":
+				entry.Tags = splitTags(value)
+			case "-":
+			default:
+				if value != "" {
+					entry.Fields[strings.TrimPrefix(target, "@")] = value
+				}
+			}
+		}
+		if !dated {
+			return nil, errors.New("line " + strconv.Itoa(line+2) + ": timestamp missing")
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	timeObj := time.Date(2021, 3, 1, 10, 30, 0, 0, time.UTC)
+	entries := []Entry{
+		{ID: "a", Title: "Title, with \"quotes\"", Content: "First line\nSecond line", Tags: []string{"work/meeting", "fun"}, Fields: map[string]string{"run": "5km", "mood": "good"}},
+		{ID: "b", Title: "Only a title", Fields: map[string]string{"run": "3km"}},
+		{ID: "c", Title: "Tabs\tand; semicolons", Fields: map[string]string{}},
+	}
+	for i := range entries {
+		entries[i].timeObj = timeObj.Add(time.Duration(i) * time.Hour)
+		entries[i].Timestamp = entries[i].timeObj.Format("2006-01-02 15:04:05")
+	}
+
+	for _, comma := range []rune{',', '\t'} {
+		data, e := csvEntries(entries, comma)
+		if e != nil {
+			t.Fatalf("csvEntries: unexpected error %v", e)
+		}
+		parsed, e := parseCSVEntries(data, comma, "")
+		if e != nil {
+			t.Fatalf("parseCSVEntries: unexpected error %v", e)
+		}
+		if len(parsed) != len(entries) {
+			t.Fatalf("parseCSVEntries returned %d entries, want %d", len(parsed), len(entries))
+		}
+		for i, entry := range entries {
+			got := parsed[i]
+			if got.ID != entry.ID || got.Title != entry.Title || got.Content != entry.Content ||
+				!got.timeObj.Equal(entry.timeObj) || !reflect.DeepEqual(got.Tags, entry.Tags) ||
+				!reflect.DeepEqual(got.Fields, entry.Fields) {
+				t.Errorf("entry %d (separator %q) = %+v, want %+v", i, comma, got, entry)
+			}
+		}
+	}
+}
+
+func TestParseCSVEntries(t *testing.T) {
+	data := "Day,Notes,Hours,Device\n2021-03-01,slept well,8,watch\n2021-03-02 23:30,,6.5,\n"
+	parsed, e := parseCSVEntries([]byte(data), ',', "Day=timestamp,Notes=content,Hours=@sleep,Device=-")
+	if e != nil {
+		t.Fatalf("parseCSVEntries: unexpected error %v", e)
+	}
+	want := []struct {
+		date, content string
+		fields        map[string]string
+	}{
+		{"2021-03-01 00:00", "slept well", map[string]string{"sleep": "8"}},
+		{"2021-03-02 23:30", "", map[string]string{"sleep": "6.5"}},
+	}
+	if len(parsed) != len(want) {
+		t.Fatalf("parseCSVEntries returned %d entries, want %d", len(parsed), len(want))
+	}
+	for i, w := range want {
+		got := parsed[i]
+		if got.timeObj.Format("2006-01-02 15:04") != w.date || got.Content != w.content || !reflect.DeepEqual(got.Fields, w.fields) {
+			t.Errorf("entry %d = %+v, want %+v", i, got, w)
+		}
+	}
+
+	failures := []struct {
+		data, mapping, message string
+	}{
+		{"title\nx\n", "", "no timestamp column"},
+		{"timestamp,title\n", "", "no entries"},
+		{"timestamp,title\nyesterday,x\n", "", "line 2: cannot parse timestamp"},
+		{"timestamp,title\n2021-03-01,x\n,y\n", "", "line 3: timestamp missing"},
+		{"title,content,timestamp\nx,y\n", "", "line 2: timestamp missing"},
+		{"timestamp,title\n2021-03-01,x\n", "Missing=title", "columns not found"},
+		{"timestamp,title\n2021-03-01,x\n", "title=body", "unknown column target"},
+	}
+	for _, test := range failures {
+		_, e := parseCSVEntries([]byte(test.data), ',', test.mapping)
+		if e == nil || !strings.Contains(e.Error(), test.message) {
+			t.Errorf("parseCSVEntries(%q, %q) error = %v, want %q", test.data, test.mapping, e, test.message)
+		}
+	}
+}
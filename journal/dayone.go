@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// location of a Day One entry
+type dayOneLocation struct {
+	PlaceName          string   `json:"placeName"`
+	LocalityName       string   `json:"localityName"`
+	AdministrativeArea string   `json:"administrativeArea"`
+	Country            string   `json:"country"`
+	Latitude           *float64 `json:"latitude"`
+	Longitude          *float64 `json:"longitude"`
+}
+
+// entry of a Day One JSON export
+type dayOneEntry struct {
+	UUID         string          `json:"uuid"`
+	CreationDate string          `json:"creationDate"`
+	TimeZone     string          `json:"timeZone"`
+	Text         string          `json:"text"`
+	Starred      bool            `json:"starred"`
+	Tags         []string        `json:"tags"`
+	Location     *dayOneLocation `json:"location"`
+}
+
+// Day One JSON export (Journal.json in the exported zip)
+type dayOneExport struct {
+	Metadata map[string]interface{} `json:"metadata"`
+	Entries  []dayOneEntry          `json:"entries"`
+}
+
+// adds the fields describing a location: its name and its coordinates
+func dayOneLocationFields(location *dayOneLocation, fields map[string]string) {
+	if location == nil {
+		return
+	}
+
+	var names []string
+	for _, n := range []string{location.PlaceName, location.LocalityName, location.AdministrativeArea, location.Country} {
+		if n = strings.TrimSpace(n); n != "" && (len(names) == 0 || names[len(names)-1] != n) {
+			names = append(names, n)
+		}
+	}
+	if len(names) > 0 {
+		fields["location"] = strings.Join(names, ", ")
+	}
+	if location.Latitude != nil && location.Longitude != nil {
+		fields["coordinates"] = strconv.FormatFloat(*location.Latitude, 'f', -1, 64) + "," + strconv.FormatFloat(*location.Longitude, 'f', -1, 64)
+	}
+}
+
+// reads the entries of a Day One JSON export. The first line of the
+// text is the title. Times are converted to the time zone of the entry
+func parseDayOneEntries(data []byte) (entries []Entry, e error) {
+	var export dayOneExport
+	if e := json.Unmarshal(data, &export); e != nil {
+		return nil, errors.New("cannot parse Day One export: " + e.Error())
+	}
+
+	for _, d := range export.Entries {
+		timeObj, e := time.Parse(time.RFC3339, d.CreationDate)
+		if e != nil {
+			return nil, errors.New("cannot parse timestamp '" + d.CreationDate + "'")
+		}
+		if location, e := time.LoadLocation(d.TimeZone); d.TimeZone != "" && e == nil {
+			timeObj = timeObj.In(location)
+		} else {
+			timeObj = timeObj.Local()
+		}
+		// keep the wall clock of the entries
+		timeObj = time.Date(timeObj.Year(), timeObj.Month(), timeObj.Day(), timeObj.Hour(), timeObj.Minute(), timeObj.Second(), 0, time.UTC)
+
+		// Day One tags can contain spaces
+		var tags []string
+		for _, t := range d.Tags {
+			tags = append(tags, strings.Join(strings.Fields(t), "-"))
+		}
+
+		// the title is the first line, often a Markdown heading
+		title, content := d.Text, ""
+		if i := strings.Index(d.Text, "\n"); i != -1 {
+			title, content = d.Text[:i], d.Text[i+1:]
+		}
+
+		entry := Entry{
+			Title:   strings.TrimSpace(strings.TrimLeft(title, "#")),
+			Content: strings.TrimSpace(content),
+			Tags:    importedTags(tags, d.Starred),
+			Fields:  make(map[string]string),
+			timeObj: timeObj,
+		}
+		dayOneLocationFields(d.Location, entry.Fields)
+		entries = append(entries, entry)
+	}
+
+	if len(entries) == 0 {
+		return nil, errors.New("no entries found in file")
+	}
+	return entries, nil
+}
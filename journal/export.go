@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// file of an export, with its path relative to the export folder
+type exportFile struct {
+	path    string
+	content []byte
+}
+
+// returns the entries sorted by time, then by ID so that exports are deterministic
+func sortedEntries(entries []Entry) []Entry {
+	sorted := make([]Entry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, k int) bool {
+		if !sorted[i].timeObj.Equal(sorted[k].timeObj) {
+			return sorted[i].timeObj.Before(sorted[k].timeObj)
+		}
+		return sorted[i].ID < sorted[k].ID
+	})
+	return sorted
+}
+
+// returns the keys of the fields of an entry, sorted
+func sortedFieldKeys(fields map[string]string) (keys []string) {
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// write an entry as Markdown, with a heading containing the title
+func writeMarkdownEntry(b *strings.Builder, entry Entry, heading string) {
+	fmt.Fprintf(b, "%s %s\n\n", heading, entry.Title)
+	if entry.Content != "" {
+		b.WriteString(entry.Content + "\n\n")
+	}
+	if len(entry.Tags) > 0 {
+		b.WriteString("+" + strings.Join(entry.Tags, " +") + "\n\n")
+	}
+	if len(entry.Fields) > 0 {
+		var fields []string
+		for _, k := range sortedFieldKeys(entry.Fields) {
+			fields = append(fields, "@"+k+"="+entry.Fields[k])
+		}
+		b.WriteString(strings.Join(fields, " ") + "\n\n")
+	}
+}
+
+// returns a list of strings in YAML flow style (e.g. ["a", "b"])
+func yamlList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = strconv.Quote(v)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// returns the YAML front matter of a group of entries, containing
+// the date, the tags and the values of the fields
+func markdownFrontMatter(date string, entries []Entry) string {
+	var b strings.Builder
+	b.WriteString("---\n")
+	b.WriteString("date: " + date + "\n")
+	b.WriteString("entries: " + strconv.Itoa(len(entries)) + "\n")
+
+	// tags, without duplicates
+	var tags []string
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		for _, t := range entry.Tags {
+			if !seen[t] {
+				seen[t] = true
+				tags = append(tags, t)
+			}
+		}
+	}
+	sort.Strings(tags)
+	if len(tags) > 0 {
+		b.WriteString("tags: " + yamlList(tags) + "\n")
+	}
+
+	// fields, with a list of values if there's more than one
+	values := make(map[string][]string)
+	for _, entry := range entries {
+		for _, k := range sortedFieldKeys(entry.Fields) {
+			values[k] = append(values[k], entry.Fields[k])
+		}
+	}
+	if len(values) > 0 {
+		var keys []string
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		b.WriteString("fields:\n")
+		for _, k := range keys {
+			if len(values[k]) == 1 {
+				b.WriteString("  " + strconv.Quote(k) + ": " + strconv.Quote(values[k][0]) + "\n")
+			} else {
+				b.WriteString("  " + strconv.Quote(k) + ": " + yamlList(values[k]) + "\n")
+			}
+		}
+	}
+
+	b.WriteString("---\n\n")
+	return b.String()
+}
+
+// returns the Markdown files of the entries, one per day (YYYY/MM/DD.md)
+// or one per month (YYYY-MM.md)
+func markdownFiles(entries []Entry, by string) (files []exportFile, e error) {
+	var pathFormat, dateFormat, headingFormat string
+	switch by {
+	case "day":
+		pathFormat, dateFormat, headingFormat = "2006/01/02.md", "2006-01-02", "15:04"
+	case "month":
+		pathFormat, dateFormat, headingFormat = "2006-01.md", "2006-01", "2006-01-02 15:04"
+	default:
+		return nil, errors.New("cannot export by '" + by + "'. Values: day, month")
+	}
+
+	// group the entries by file, keeping the order
+	var paths []string
+	groups := make(map[string][]Entry)
+	for _, entry := range sortedEntries(entries) {
+		path := filepath.FromSlash(entry.timeObj.Format(pathFormat))
+		if _, ok := groups[path]; !ok {
+			paths = append(paths, path)
+		}
+		groups[path] = append(groups[path], entry)
+	}
+
+	for _, path := range paths {
+		group := groups[path]
+		var b strings.Builder
+		b.WriteString(markdownFrontMatter(group[0].timeObj.Format(dateFormat), group))
+		for _, entry := range group {
+			writeMarkdownEntry(&b, entry, "## "+entry.timeObj.Format(headingFormat))
+		}
+		content := strings.TrimRight(b.String(), "\n") + "\n"
+		files = append(files, exportFile{path: path, content: []byte(content)})
+	}
+
+	return files, nil
+}
+
+// files written by the markdown export: YYYY/MM/DD.md and YYYY-MM.md
+var markdownExportPath = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2}|\d{4}-\d{2})\.md$`)
+
+// remove the markdown files of a previous export that are not part of
+// this one (e.g. days without entries anymore), with the folders left empty
+func removeStaleFiles(folder string, files []exportFile) (removed int, e error) {
+	exported := make(map[string]bool)
+	for _, f := range files {
+		exported[filepath.ToSlash(f.path)] = true
+	}
+
+	var stale []string
+	e = filepath.Walk(folder, func(path string, info os.FileInfo, e error) error {
+		if e != nil || info.IsDir() {
+			return e
+		}
+		relative, e := filepath.Rel(folder, path)
+		if e != nil {
+			return e
+		}
+		relative = filepath.ToSlash(relative)
+		if markdownExportPath.MatchString(relative) && !exported[relative] {
+			stale = append(stale, path)
+		}
+		return nil
+	})
+	if e != nil {
+		return 0, errors.New("cannot read folder " + folder)
+	}
+
+	for _, path := range stale {
+		if e := os.Remove(path); e != nil {
+			return removed, errors.New("cannot remove file " + path)
+		}
+		removed++
+		// month and year folders, only if empty
+		month := filepath.Dir(path)
+		if os.Remove(month) == nil {
+			os.Remove(filepath.Dir(month))
+		}
+	}
+	return removed, nil
+}
+
+// write the files in a folder. Files that wouldn't change are not written
+func writeExportFiles(folder string, files []exportFile) (written, unchanged int, e error) {
+	for _, f := range files {
+		path := filepath.Join(folder, f.path)
+		if old, e := readFromFile(path); e == nil && bytes.Equal(old, f.content) {
+			unchanged++
+			continue
+		}
+
+		if e := os.MkdirAll(filepath.Dir(path), 0755); e != nil {
+			return written, unchanged, errors.New("cannot create folder " + filepath.Dir(path))
+		}
+		if e := writeToFile(path, f.content); e != nil {
+			return written, unchanged, e
+		}
+		written++
+	}
+	return written, unchanged, nil
+}
+
+// export the entries to a folder or, for the formats
+// written in a single file, to a file. Exporting to a folder
+// removes the files of the entries that are not exported anymore
+// formats: markdown, csv, tsv, ics, org
+func exportEntries(entries []Entry, path, format, by string, allDay bool) (written, unchanged, removed int, e error) {
+	if len(entries) == 0 {
+		return 0, 0, 0, errors.New("no entries to export")
+	}
+
+	var files []exportFile
+	folder := path
+	markdown := false
+	switch fileFormat(path, format) {
+	case "markdown", "md", "":
+		files, e = markdownFiles(entries, by)
+		markdown = true
+	case "csv", "tsv":
+		comma := ','
+		if fileFormat(path, format) == "tsv" {
+			comma = '\t'
+		}
+		var content []byte
+		content, e = csvEntries(entries, comma)
+		folder = filepath.Dir(path)
+		files = []exportFile{{path: filepath.Base(path), content: content}}
+	case "ics":
+		folder = filepath.Dir(path)
+		files = []exportFile{{path: filepath.Base(path), content: icsEntries(entries, allDay)}}
+	case "org":
+		folder = filepath.Dir(path)
+		files = []exportFile{{path: filepath.Base(path), content: orgEntries(entries)}}
+	default:
+		return 0, 0, 0, errors.New("unknown export format '" + fileFormat(path, format) + "'. Formats: markdown, csv, tsv, ics, org")
+	}
+	if e != nil {
+		return 0, 0, 0, e
+	}
+
+	written, unchanged, e = writeExportFiles(folder, files)
+	if e != nil || !markdown {
+		return written, unchanged, 0, e
+	}
+	removed, e = removeStaleFiles(folder, files)
+	return written, unchanged, removed, e
+}
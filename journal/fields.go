@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldRule contains the rules that the values of a field must follow
+type FieldRule struct {
+	// number or text. If empty, any value is allowed
+	Type string `json:"type,omitempty"`
+	// unit of numeric values (e.g. km). Values can omit it
+	Unit string `json:"unit,omitempty"`
+	// allowed values
+	Values []string `json:"values,omitempty"`
+	// allowed range of numeric values
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+	// the field is mandatory in entries with these tags (or their descendants)
+	RequiredFor []string `json:"requiredFor,omitempty"`
+}
+
+// FieldSchema maps each allowed field key to its rule
+type FieldSchema map[string]FieldRule
+
+// single field that doesn't follow the schema
+type fieldViolation struct {
+	entry   Entry
+	problem error
+}
+
+// load and check a schema from a JSON file
+func loadFieldSchema(path string) (schema FieldSchema, e error) {
+	file, e := readFromFile(path)
+	if e != nil {
+		return nil, errors.New("cannot open schema file " + path)
+	}
+
+	e = json.Unmarshal(file, &schema)
+	if e != nil {
+		return nil, errors.New("cannot parse schema file " + path)
+	}
+
+	for key, rule := range schema {
+		if key == "" {
+			return nil, errors.New("empty field key in schema")
+		}
+		switch rule.Type {
+		case "", "text":
+			if rule.Unit != "" || rule.Min != nil || rule.Max != nil {
+				return nil, errors.New("field '" + key + "': unit, min and max can only be used with numbers")
+			}
+		case "number":
+			if rule.Min != nil && rule.Max != nil && *rule.Min > *rule.Max {
+				return nil, errors.New("field '" + key + "': min is greater than max")
+			}
+		default:
+			return nil, errors.New("field '" + key + "': unknown type '" + rule.Type + "'. Types: number, text")
+		}
+	}
+
+	return schema, nil
+}
+
+// parses a numeric value, optionally followed by the unit (e.g. 10km or 10 km)
+func parseNumberWithUnit(value, unit string) (number float64, e error) {
+	value = strings.TrimSpace(value)
+	if unit != "" && strings.HasSuffix(strings.ToLower(value), strings.ToLower(unit)) {
+		value = strings.TrimSpace(value[:len(value)-len(unit)])
+	}
+
+	number, ok := parseDecimal(value)
+	if !ok {
+		if unit != "" {
+			return 0, errors.New("'" + value + "' is not a number in " + unit)
+		}
+		return 0, errors.New("'" + value + "' is not a number")
+	}
+	return number, nil
+}
+
+// returns the closest key in the schema, if similar enough
+func (schema FieldSchema) suggestKey(key string) string {
+	suggestion := ""
+	best := -1
+	for k := range schema {
+		distance := editDistance([]rune(strings.ToLower(key)), []rune(strings.ToLower(k)))
+		if distance <= maxEditDistance([]rune(k)) && (best == -1 || distance < best || (distance == best && k < suggestion)) {
+			suggestion = k
+			best = distance
+		}
+	}
+	return suggestion
+}
+
+// check the tags and the fields of an entry against the schema
+// an empty schema allows any field
+func (schema FieldSchema) validate(tags []string, fields map[string]string) (problems []error) {
+	if len(schema) == 0 {
+		return nil
+	}
+
+	// check the keys in deterministic order
+	var keys []string
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := fields[key]
+		rule, ok := schema[key]
+		if !ok {
+			message := "unknown field '" + key + "'"
+			if suggestion := schema.suggestKey(key); suggestion != "" {
+				message += ". Did you mean '" + suggestion + "'?"
+			}
+			problems = append(problems, errors.New(message))
+			continue
+		}
+
+		if rule.Type == "number" {
+			number, e := parseNumberWithUnit(value, rule.Unit)
+			if e != nil {
+				problems = append(problems, errors.New("field '"+key+"': "+e.Error()))
+				continue
+			}
+			if rule.Min != nil && number < *rule.Min {
+				problems = append(problems, errors.New("field '"+key+"': "+value+" is lower than the minimum "+strconv.FormatFloat(*rule.Min, 'f', -1, 64)))
+			}
+			if rule.Max != nil && number > *rule.Max {
+				problems = append(problems, errors.New("field '"+key+"': "+value+" is greater than the maximum "+strconv.FormatFloat(*rule.Max, 'f', -1, 64)))
+			}
+		}
+
+		if len(rule.Values) > 0 {
+			allowed := false
+			for _, v := range rule.Values {
+				if strings.EqualFold(v, value) {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				problems = append(problems, errors.New("field '"+key+"': '"+value+"' is not one of "+strings.Join(rule.Values, ", ")))
+			}
+		}
+	}
+
+	// check the required fields
+	var schemaKeys []string
+	for k := range schema {
+		schemaKeys = append(schemaKeys, k)
+	}
+	sort.Strings(schemaKeys)
+
+	for _, key := range schemaKeys {
+		if _, ok := fields[key]; ok {
+			continue
+		}
+		for _, required := range schema[key].RequiredFor {
+			if entryHasAnyTag(Entry{Tags: tags}, []string{cleanTag(required)}) {
+				problems = append(problems, errors.New("field '"+key+"' is required for tag '"+required+"'"))
+				break
+			}
+		}
+	}
+
+	return problems
+}
+
+// check all the entries against the schema
+func (j *Journal) lintFields() (violations []fieldViolation, e error) {
+	if len(j.FieldSchema) == 0 {
+		return nil, errors.New("the journal has no field schema. Set one with --setschema")
+	}
+
+	for _, entry := range j.Entries {
+		for _, p := range j.FieldSchema.validate(entry.Tags, entry.Fields) {
+			violations = append(violations, fieldViolation{entry: entry, problem: p})
+		}
+	}
+
+	return violations, nil
+}
+
+// joins multiple errors into a single one, one per line
+func joinErrors(problems []error) error {
+	var messages []string
+	for _, p := range problems {
+		messages = append(messages, p.Error())
+	}
+	return errors.New(strings.Join(messages, "\n"))
+}
+
+// summary of the values of a field
+type fieldSummary struct {
+	key string
+	// number of entries using the field
+	count int
+	// distinct value -> number of entries
+	values map[string]int
+	// true if all the values are numbers
+	numeric             bool
+	min, max, sum, mean float64
+}
+
+// returns the distinct values, most used first
+func (f fieldSummary) sortedValues() (sorted []string) {
+	for v := range f.values {
+		sorted = append(sorted, v)
+	}
+	sort.Slice(sorted, func(i, k int) bool {
+		if f.values[sorted[i]] != f.values[sorted[k]] {
+			return f.values[sorted[i]] > f.values[sorted[k]]
+		}
+		return sorted[i] < sorted[k]
+	})
+	return sorted
+}
+
+// summarize each field used in the entries, sorted by key
+func summarizeFields(entries []Entry) (fields []fieldSummary) {
+	summaries := make(map[string]*fieldSummary)
+
+	for _, entry := range entries {
+		for k, v := range entry.Fields {
+			f, ok := summaries[k]
+			if !ok {
+				f = &fieldSummary{key: k, values: make(map[string]int), numeric: true}
+				summaries[k] = f
+			}
+
+			f.count++
+			f.values[v]++
+
+			number, isNumber := parseNumber(v)
+			if !isNumber {
+				f.numeric = false
+				continue
+			}
+			if f.count == 1 || number < f.min {
+				f.min = number
+			}
+			if f.count == 1 || number > f.max {
+				f.max = number
+			}
+			f.sum += number
+		}
+	}
+
+	for _, f := range summaries {
+		if f.numeric {
+			f.mean = f.sum / float64(f.count)
+		}
+		fields = append(fields, *f)
+	}
+	sort.Slice(fields, func(i, k int) bool { return fields[i].key < fields[k].key })
+
+	return fields
+}
+
+// values of a field in a period of time
+type fieldPeriod struct {
+	start time.Time
+	label string
+	// number of entries using the field
+	count int
+	// number of numeric values
+	numbers       int
+	min, max, sum float64
+}
+
+// returns the mean of the numeric values
+func (p fieldPeriod) mean() float64 {
+	if p.numbers == 0 {
+		return 0
+	}
+	return p.sum / float64(p.numbers)
+}
+
+// aggregate the values of a field by day, week, month or year
+// periods without the field are skipped
+func aggregateField(entries []Entry, key, period string) (periods []fieldPeriod, e error) {
+	key = strings.TrimPrefix(key, "@")
+	byStart := make(map[time.Time]*fieldPeriod)
+
+	for _, entry := range entries {
+		value, ok := entry.Fields[key]
+		if !ok {
+			continue
+		}
+
+		start, e := periodStart(entry.timeObj, period)
+		if e != nil {
+			return nil, e
+		}
+
+		p, ok := byStart[start]
+		if !ok {
+			p = &fieldPeriod{start: start, label: periodLabel(start, period)}
+			byStart[start] = p
+		}
+		p.count++
+
+		if number, isNumber := parseNumber(value); isNumber {
+			if p.numbers == 0 || number < p.min {
+				p.min = number
+			}
+			if p.numbers == 0 || number > p.max {
+				p.max = number
+			}
+			p.sum += number
+			p.numbers++
+		}
+	}
+
+	if len(byStart) == 0 {
+		return nil, errors.New("no entries found with the field")
+	}
+
+	for _, p := range byStart {
+		periods = append(periods, *p)
+	}
+	sort.Slice(periods, func(i, k int) bool { return periods[i].start.Before(periods[k].start) })
+
+	return periods, nil
+}
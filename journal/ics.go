@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// suffix of the UIDs of the exported entries
+const icsUIDSuffix = "@journal"
+
+// prefix of the properties containing the fields of the entries
+const icsFieldPrefix = "X-JOURNAL-FIELD-"
+
+// maximum length of a line, in bytes (without the line break)
+const icsLineLength = 75
+
+// property of an iCalendar component (e.g. SUMMARY:Title)
+type icsProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// escapes a text value
+func icsEscape(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(text)
+}
+
+// unescapes a text value
+func icsUnescape(text string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range text {
+		if escaped {
+			if r == 'n' || r == 'N' {
+				b.WriteRune('\n')
+			} else {
+				b.WriteRune(r)
+			}
+			escaped = false
+		} else if r == '\\' {
+			escaped = true
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// splits a list of values separated by unescaped commas
+func icsSplitList(value string) (values []string) {
+	start := 0
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' {
+			i++
+		} else if value[i] == ',' {
+			values = append(values, icsUnescape(value[start:i]))
+			start = i + 1
+		}
+	}
+	return append(values, icsUnescape(value[start:]))
+}
+
+// writes a line, folding it if it's longer than the maximum length
+func icsWriteLine(b *bytes.Buffer, line string) {
+	length := icsLineLength
+	for len(line) > length {
+		// don't split multibyte characters
+		cut := length
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		// the continuation lines start with a space
+		length = icsLineLength - 1
+	}
+	b.WriteString(line + "\r\n")
+}
+
+// returns the name of the property containing a field
+func icsFieldProperty(key string) string {
+	return icsFieldPrefix + strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToUpper(r)
+		}
+		return '-'
+	}, key)
+}
+
+// returns the entries as an iCalendar file, each entry being a VJOURNAL
+// or, if allDay is true, an all-day VEVENT
+func icsEntries(entries []Entry, allDay bool) []byte {
+	var b bytes.Buffer
+	icsWriteLine(&b, "BEGIN:VCALENDAR")
+	icsWriteLine(&b, "VERSION:2.0")
+	icsWriteLine(&b, "PRODID:-//lorossi//journal//EN")
+
+	for _, entry := range sortedEntries(entries) {
+		component := "VJOURNAL"
+		if allDay {
+			component = "VEVENT"
+		}
+
+		icsWriteLine(&b, "BEGIN:"+component)
+		icsWriteLine(&b, "UID:"+entry.ID+icsUIDSuffix)
+		// the time of the entries is the local wall clock
+		stamp := time.Date(entry.timeObj.Year(), entry.timeObj.Month(), entry.timeObj.Day(), entry.timeObj.Hour(), entry.timeObj.Minute(), entry.timeObj.Second(), 0, time.Local)
+		icsWriteLine(&b, "DTSTAMP:"+stamp.UTC().Format("20060102T150405Z"))
+		if allDay {
+			icsWriteLine(&b, "DTSTART;VALUE=DATE:"+entry.timeObj.Format("20060102"))
+			icsWriteLine(&b, "DTEND;VALUE=DATE:"+entry.timeObj.AddDate(0, 0, 1).Format("20060102"))
+		} else {
+			icsWriteLine(&b, "DTSTART:"+entry.timeObj.Format("20060102T150405"))
+		}
+		icsWriteLine(&b, "SUMMARY:"+icsEscape(entry.Title))
+		if entry.Content != "" {
+			icsWriteLine(&b, "DESCRIPTION:"+icsEscape(entry.Content))
+		}
+		if len(entry.Tags) > 0 {
+			var tags []string
+			for _, t := range entry.Tags {
+				tags = append(tags, icsEscape(t))
+			}
+			icsWriteLine(&b, "CATEGORIES:"+strings.Join(tags, ","))
+		}
+		for _, k := range sortedFieldKeys(entry.Fields) {
+			icsWriteLine(&b, icsFieldProperty(k)+";X-KEY=\""+strings.ReplaceAll(k, "\"", "'")+"\":"+icsEscape(entry.Fields[k]))
+		}
+		icsWriteLine(&b, "END:"+component)
+	}
+
+	icsWriteLine(&b, "END:VCALENDAR")
+	return b.Bytes()
+}
+
+// returns the lines of an iCalendar file, unfolding the long ones
+func icsUnfold(data []byte) (lines []string) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	for _, line := range strings.Split(text, "\n") {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+		} else if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// parses a line in format NAME;PARAM=value:value
+func icsParseLine(line string) (p icsProperty, e error) {
+	// the colon before the value is the first one outside quoted parameters
+	parts := icsSplitUnquoted(line, ':', 2)
+	if len(parts) < 2 {
+		return p, errors.New("cannot parse line '" + line + "'")
+	}
+
+	p.value = parts[1]
+	p.params = make(map[string]string)
+	params := icsSplitUnquoted(parts[0], ';', -1)
+	p.name = strings.ToUpper(params[0])
+	for _, param := range params[1:] {
+		if kv := strings.SplitN(param, "=", 2); len(kv) == 2 {
+			p.params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], "\"")
+		}
+	}
+	return p, nil
+}
+
+// splits a line at the separators that are not inside quotes, in at
+// most max parts (all of them if max is negative)
+func icsSplitUnquoted(line string, separator rune, max int) (parts []string) {
+	quoted := false
+	start := 0
+	for i, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == separator && !quoted && len(parts) != max-1 {
+			parts = append(parts, line[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, line[start:])
+}
+
+// parses the start date of a component, in the local time zone if not specified
+func icsParseDate(p icsProperty) (timeObj time.Time, e error) {
+	location := time.UTC
+	if tzid, ok := p.params["TZID"]; ok {
+		if l, e := time.LoadLocation(tzid); e == nil {
+			location = l
+		}
+	}
+
+	switch {
+	case p.params["VALUE"] == "DATE" || len(p.value) == 8:
+		return time.Parse("20060102", p.value)
+	case strings.HasSuffix(p.value, "Z"):
+		timeObj, e = time.Parse("20060102T150405Z", p.value)
+		timeObj = timeObj.Local()
+	default:
+		timeObj, e = time.ParseInLocation("20060102T150405", p.value, location)
+		if location != time.UTC {
+			timeObj = timeObj.Local()
+		}
+	}
+	if e != nil {
+		return time.Time{}, errors.New("cannot parse date '" + p.value + "'")
+	}
+
+	// keep the wall clock of the entries
+	return time.Date(timeObj.Year(), timeObj.Month(), timeObj.Day(), timeObj.Hour(), timeObj.Minute(), timeObj.Second(), 0, time.UTC), nil
+}
+
+// reads the VJOURNAL components of an iCalendar file as entries
+func parseICSEntries(data []byte) (entries []Entry, e error) {
+	var entry *Entry
+	hasDate := false
+
+	for _, line := range icsUnfold(data) {
+		p, e := icsParseLine(line)
+		if e != nil {
+			return nil, e
+		}
+
+		switch {
+		case p.name == "BEGIN" && strings.ToUpper(p.value) == "VJOURNAL":
+			entry = &Entry{Fields: make(map[string]string)}
+			hasDate = false
+		case p.name == "END" && strings.ToUpper(p.value) == "VJOURNAL" && entry != nil:
+			if !hasDate {
+				return nil, errors.New("journal entry '" + entry.Title + "' has no start date")
+			}
+			entries = append(entries, *entry)
+			entry = nil
+		case entry == nil:
+			// property of another component
+		case p.name == "UID":
+			if strings.HasSuffix(p.value, icsUIDSuffix) {
+				entry.ID = strings.TrimSuffix(p.value, icsUIDSuffix)
+			}
+		case p.name == "DTSTART":
+			if entry.timeObj, e = icsParseDate(p); e != nil {
+				return nil, e
+			}
+			hasDate = true
+		case p.name == "SUMMARY":
+			entry.Title = icsUnescape(p.value)
+		case p.name == "DESCRIPTION":
+			entry.Content = icsUnescape(p.value)
+		case p.name == "CATEGORIES":
+			for _, t := range icsSplitList(p.value) {
+				entry.Tags = append(entry.Tags, splitTags(t)...)
+			}
+		case strings.HasPrefix(p.name, icsFieldPrefix):
+			key, ok := p.params["X-KEY"]
+			if !ok {
+				key = strings.ToLower(strings.TrimPrefix(p.name, icsFieldPrefix))
+			}
+			entry.Fields[key] = icsUnescape(p.value)
+		}
+	}
+
+	if len(entries) == 0 {
+		return nil, errors.New("no journal entries found in file")
+	}
+	return entries, nil
+}
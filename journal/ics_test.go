@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestICSWriteLine(t *testing.T) {
+	tests := []string{
+		"SUMMARY:short",
+		"DESCRIPTION:" + strings.Repeat("a", 63),
+		"DESCRIPTION:" + strings.Repeat("a", 64),
+		"DESCRIPTION:" + strings.Repeat("abcdefghij", 30),
+		"DESCRIPTION:" + strings.Repeat("àèìòù€", 40),
+	}
+
+	for _, line := range tests {
+		var b bytes.Buffer
+		icsWriteLine(&b, line)
+		folded := strings.TrimSuffix(b.String(), "\r\n")
+
+		for i, physical := range strings.Split(folded, "\r\n") {
+			if len(physical) > icsLineLength {
+				t.Errorf("line %d of %q is %d octets long", i, line, len(physical))
+			}
+			if i > 0 && !strings.HasPrefix(physical, " ") {
+				t.Errorf("continuation line %d of %q doesn't start with a space", i, line)
+			}
+			if !utf8.ValidString(strings.TrimPrefix(physical, " ")) {
+				t.Errorf("line %d of %q splits a character", i, line)
+			}
+		}
+
+		if unfolded := icsUnfold(b.Bytes()); len(unfolded) != 1 || unfolded[0] != line {
+			t.Errorf("icsUnfold(icsWriteLine(%q)) = %q", line, unfolded)
+		}
+	}
+}
+
+func TestICSParseLine(t *testing.T) {
+	tests := []struct {
+		line   string
+		name   string
+		params map[string]string
+		value  string
+	}{
+		{"SUMMARY:Title", "SUMMARY", map[string]string{}, "Title"},
+		{"summary:a:b", "SUMMARY", map[string]string{}, "a:b"},
+		{"DTSTART;VALUE=DATE:20210301", "DTSTART", map[string]string{"VALUE": "DATE"}, "20210301"},
+		{"DTSTART;TZID=Europe/Rome:20210301T100000", "DTSTART", map[string]string{"TZID": "Europe/Rome"}, "20210301T100000"},
+		{`ORGANIZER;CN="Doe; John":mailto:a@b.c`, "ORGANIZER", map[string]string{"CN": "Doe; John"}, "mailto:a@b.c"},
+		{`X-JOURNAL-FIELD-A;X-KEY="a:b;c":1`, "X-JOURNAL-FIELD-A", map[string]string{"X-KEY": "a:b;c"}, "1"},
+	}
+
+	for _, test := range tests {
+		p, e := icsParseLine(test.line)
+		if e != nil {
+			t.Errorf("icsParseLine(%q): unexpected error %v", test.line, e)
+			continue
+		}
+		if p.name != test.name || p.value != test.value || !reflect.DeepEqual(p.params, test.params) {
+			t.Errorf("icsParseLine(%q) = %q %v %q, want %q %v %q", test.line, p.name, p.params, p.value, test.name, test.params, test.value)
+		}
+	}
+
+	if _, e := icsParseLine("SUMMARY"); e == nil {
+		t.Errorf("icsParseLine(%q): expected an error", "SUMMARY")
+	}
+}
+
+func TestICSRoundTrip(t *testing.T) {
+	timeObj := time.Date(2021, 3, 1, 10, 30, 0, 0, time.Local)
+	entries := []Entry{
+		{
+			ID:      "0a1b2c3d",
+			Title:   "Title, with; special\\ characters",
+			Content: "First line\nSecond line " + strings.Repeat("long text ", 20),
+			Tags:    []string{"work/meeting", "fun"},
+			Fields:  map[string]string{"run": "5km", "a;b:c": "x, y"},
+			timeObj: timeObj,
+		},
+		{ID: "4e5f6a7b", Title: "Only a title", Fields: map[string]string{}, timeObj: timeObj.Add(time.Hour)},
+	}
+
+	parsed, e := parseICSEntries(icsEntries(entries, false))
+	if e != nil {
+		t.Fatalf("parseICSEntries: unexpected error %v", e)
+	}
+	if len(parsed) != len(entries) {
+		t.Fatalf("parseICSEntries returned %d entries, want %d", len(parsed), len(entries))
+	}
+	for i, entry := range entries {
+		got := parsed[i]
+		if got.ID != entry.ID || got.Title != entry.Title || got.Content != entry.Content ||
+			!got.timeObj.Equal(entry.timeObj) || !reflect.DeepEqual(got.Tags, entry.Tags) ||
+			!reflect.DeepEqual(got.Fields, entry.Fields) {
+			t.Errorf("entry %d = %+v, want %+v", i, got, entry)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// tag added to the starred entries of jrnl and Day One
+const starredTag = "starred"
+
+// layouts accepted for the timestamps of imported entries
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// parses the timestamp of an imported entry
+func parseTimestamp(value string) (timeObj time.Time, e error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range timestampLayouts {
+		if timeObj, e = time.Parse(layout, value); e == nil {
+			if strings.Contains(layout, "Z07") {
+				timeObj = timeObj.Local()
+			}
+			return timeObj, nil
+		}
+	}
+	return time.Time{}, errors.New("cannot parse timestamp '" + value + "'")
+}
+
+// splits a list of tags separated by spaces or commas, removing the leading +
+func splitTags(value string) (tags []string) {
+	for _, t := range strings.FieldsFunc(value, func(r rune) bool { return r == ' ' || r == ',' }) {
+		if t = cleanTag(strings.TrimPrefix(t, "+")); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// returns the tags of an entry imported from another program,
+// without the leading @ or #. Starred entries get the starred tag
+func importedTags(tags []string, starred bool) (clean []string) {
+	for _, t := range tags {
+		if t = cleanTag(strings.TrimLeft(t, "@#")); t != "" {
+			clean = append(clean, t)
+		}
+	}
+	if starred {
+		clean = append(clean, starredTag)
+	}
+	return uniqueTags(clean)
+}
+
+// returns the format of a file: the one provided or, if empty,
+// the extension of the file. Folders have no format, even if their
+// name contains a dot
+func fileFormat(path, format string) string {
+	if format != "" {
+		return format
+	}
+	if info, e := os.Stat(path); e == nil && info.IsDir() {
+		return ""
+	}
+	return strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// check if the journal already has an entry written in the same
+// minute, with the same title and content
+func (j *Journal) hasDuplicate(entry Entry) bool {
+	return containsDuplicate(j.Entries, entry)
+}
+
+// check if a list contains an entry with the same minute, title and content
+func containsDuplicate(entries []Entry, entry Entry) bool {
+	for _, e := range entries {
+		if sameMinute(e.timeObj, entry.timeObj) && e.Title == entry.Title && e.Content == entry.Content {
+			return true
+		}
+	}
+	return false
+}
+
+// add the imported entries to the journal, applying the tag aliases and
+// checking the fields against the schema. If any entry is not valid,
+// nothing is imported. Entries with an ID already in the journal and
+// duplicates of existing entries are skipped
+func (j *Journal) importEntries(entries []Entry) (imported, skipped int, e error) {
+	var problems []error
+	var valid []Entry
+	seen := make(map[string]bool)
+
+	for _, entry := range entries {
+		if entry.ID != "" {
+			if _, found := j.findEntryByID(entry.ID); found || seen[entry.ID] {
+				skipped++
+				continue
+			}
+			seen[entry.ID] = true
+		}
+		// the file itself can contain the same entry twice
+		if j.hasDuplicate(entry) || containsDuplicate(valid, entry) {
+			skipped++
+			continue
+		}
+
+		var tags []string
+		for _, t := range entry.Tags {
+			if t = cleanTag(t); t != "" {
+				tags = append(tags, t)
+			}
+		}
+		entry.Tags = j.applyTagAliases(tags)
+		if entry.Fields == nil {
+			entry.Fields = make(map[string]string)
+		}
+
+		for _, p := range j.FieldSchema.validate(entry.Tags, entry.Fields) {
+			problems = append(problems, errors.New("["+entry.timeObj.Format(j.timeFormat)+"] "+entry.Title+": "+p.Error()))
+		}
+		valid = append(valid, entry)
+	}
+
+	if len(problems) > 0 {
+		return 0, 0, joinErrors(problems)
+	}
+
+	for _, entry := range valid {
+		newEntry := j.createNewEntry(entry.Title, entry.Content, entry.Tags, entry.Fields, entry.timeObj)
+		if entry.ID != "" {
+			newEntry.ID = entry.ID
+		}
+		newEntry.Updated = entry.Updated
+		j.Entries = append(j.Entries, newEntry)
+		imported++
+	}
+	sort.Slice(j.Entries, func(i, k int) bool { return j.Entries[i].timeObj.Before(j.Entries[k].timeObj) })
+
+	return imported, skipped, nil
+}
+
+// import the entries of a file in the journal
+// formats: csv, tsv, ics, org, jrnl (JSON or plaintext), dayone (JSON)
+func (j *Journal) importFile(path, format, columns string) (imported, skipped int, e error) {
+	data, e := readFromFile(path)
+	if e != nil {
+		return 0, 0, errors.New("cannot open file " + path)
+	}
+
+	// JSON exports can come from jrnl or Day One
+	format = fileFormat(path, format)
+	if format == "json" {
+		format = "jrnl"
+		if strings.Contains(string(data), "\"creationDate\"") {
+			format = "dayone"
+		}
+	}
+
+	var entries []Entry
+	switch format {
+	case "csv":
+		entries, e = parseCSVEntries(data, ',', columns)
+	case "tsv":
+		entries, e = parseCSVEntries(data, '\t', columns)
+	case "ics":
+		entries, e = parseICSEntries(data)
+	case "org":
+		entries, e = parseOrgEntries(data)
+	case "jrnl":
+		entries, e = parseJrnlEntries(data)
+	case "dayone":
+		entries, e = parseDayOneEntries(data)
+	default:
+		return 0, 0, errors.New("unknown import format '" + format + "'. Formats: csv, tsv, ics, org, jrnl, dayone")
+	}
+	if e != nil {
+		return 0, 0, e
+	}
+
+	return j.importEntries(entries)
+}
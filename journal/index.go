@@ -0,0 +1,498 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// version of the index format. Indexes with a different version are rebuilt
+const indexVersion = 2
+
+// BM25 parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// words around the first match shown in snippets
+const (
+	snippetBefore = 6
+	snippetAfter  = 14
+)
+
+// accented letters and ligatures folded into plain ascii
+var foldedRunes = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'č': "c", 'ď': "d", 'đ': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'į': "i", 'ı': "i",
+	'ł': "l", 'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o", 'œ': "oe",
+	'ř': "r", 'ß': "ss", 'ś': "s", 'š': "s", 'ş': "s", 'ť': "t", 'ţ': "t",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ý': "y", 'ÿ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+}
+
+// inverted index of the entries of a journal, saved next to it
+type searchIndex struct {
+	Version int `json:"version"`
+	// term -> entry ID -> number of occurrences
+	Postings map[string]map[string]int `json:"postings"`
+	// entry ID -> indexed entry
+	Documents map[string]indexedDocument `json:"documents"`
+	// true if the index changed since it was loaded
+	changed bool
+}
+
+// entry stored in the index
+type indexedDocument struct {
+	// number of terms in the entry
+	Length int `json:"length"`
+	// hash of the indexed text, used to detect changes
+	Hash string `json:"hash"`
+	// term -> number of occurrences, to remove the entry quickly
+	Terms map[string]int `json:"terms"`
+}
+
+// single search result
+type searchResult struct {
+	entry Entry
+	score float64
+}
+
+// returns an empty index
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		Version:   indexVersion,
+		Postings:  make(map[string]map[string]int),
+		Documents: make(map[string]indexedDocument),
+	}
+}
+
+// returns the text of the entry that gets indexed
+func indexedText(entry Entry) string {
+	return entry.Title + " " + entry.Content
+}
+
+// returns the hash of the text of the entry
+func indexedHash(entry Entry) string {
+	h := fnv.New64a()
+	h.Write([]byte(indexedText(entry)))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// returns the start and the end of each word in a string
+func wordSpans(text string) (spans [][2]int) {
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+		if isWord && start == -1 {
+			start = i
+		} else if !isWord && start != -1 {
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start != -1 {
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return spans
+}
+
+// converts a word to lower case, without accents
+func foldWord(word string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(word) {
+		if folded, ok := foldedRunes[r]; ok {
+			b.WriteString(folded)
+		} else if !unicode.Is(unicode.Mn, r) {
+			// combining marks (decomposed accents) are dropped
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// converts a word into an index term: lower case, without accents, stemmed
+func normalizeWord(word string) string {
+	return stem(foldWord(word))
+}
+
+// splits a text into index terms
+func tokenize(text string) (terms []string) {
+	for _, s := range wordSpans(text) {
+		if term := normalizeWord(text[s[0]:s[1]]); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// check if the letter in position i is a consonant (Porter's definition)
+func isConsonant(word []byte, i int) bool {
+	switch word[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !isConsonant(word, i-1)
+	}
+	return true
+}
+
+// number of vowel-consonant sequences in a word (Porter's measure)
+func measure(word []byte) (m int) {
+	i := 0
+	// skip the initial consonants
+	for i < len(word) && isConsonant(word, i) {
+		i++
+	}
+	for i < len(word) {
+		// skip the vowels
+		for i < len(word) && !isConsonant(word, i) {
+			i++
+		}
+		if i >= len(word) {
+			break
+		}
+		// skip the consonants
+		for i < len(word) && isConsonant(word, i) {
+			i++
+		}
+		m++
+	}
+	return m
+}
+
+// check if a word contains a vowel
+func containsVowel(word []byte) bool {
+	for i := range word {
+		if !isConsonant(word, i) {
+			return true
+		}
+	}
+	return false
+}
+
+// check if a word ends with consonant-vowel-consonant,
+// where the last consonant is not w, x or y
+func endsCVC(word []byte) bool {
+	n := len(word)
+	if n < 3 {
+		return false
+	}
+	if !isConsonant(word, n-1) || isConsonant(word, n-2) || !isConsonant(word, n-3) {
+		return false
+	}
+	last := word[n-1]
+	return last != 'w' && last != 'x' && last != 'y'
+}
+
+// light stemmer implementing the first step of the Porter algorithm
+// (plurals, -ed, -ing and final -y). Only plain ascii words are stemmed
+func stem(term string) string {
+	if len(term) <= 2 {
+		return term
+	}
+	for _, r := range term {
+		if r < 'a' || r > 'z' {
+			return term
+		}
+	}
+
+	word := []byte(term)
+	hasSuffix := func(suffix string) bool {
+		return strings.HasSuffix(string(word), suffix)
+	}
+
+	// step 1a: plurals
+	switch {
+	case hasSuffix("sses"), hasSuffix("ies"):
+		word = word[:len(word)-2]
+	case hasSuffix("ss"):
+	case hasSuffix("s"):
+		word = word[:len(word)-1]
+	}
+
+	// step 1b: -eed, -ed, -ing
+	removed := false
+	switch {
+	case hasSuffix("eed"):
+		if measure(word[:len(word)-3]) > 0 {
+			word = word[:len(word)-1]
+		}
+	case hasSuffix("ed") && containsVowel(word[:len(word)-2]):
+		word = word[:len(word)-2]
+		removed = true
+	case hasSuffix("ing") && containsVowel(word[:len(word)-3]):
+		word = word[:len(word)-3]
+		removed = true
+	}
+
+	if removed {
+		n := len(word)
+		switch {
+		case hasSuffix("at"), hasSuffix("bl"), hasSuffix("iz"):
+			word = append(word, 'e')
+		case n > 1 && word[n-1] == word[n-2] && isConsonant(word, n-1) &&
+			word[n-1] != 'l' && word[n-1] != 's' && word[n-1] != 'z':
+			word = word[:n-1]
+		case measure(word) == 1 && endsCVC(word):
+			word = append(word, 'e')
+		}
+	}
+
+	// step 1c: final y
+	if hasSuffix("y") && containsVowel(word[:len(word)-1]) {
+		word[len(word)-1] = 'i'
+	}
+
+	return string(word)
+}
+
+// add an entry to the index
+func (index *searchIndex) add(entry Entry) {
+	terms := tokenize(indexedText(entry))
+	document := indexedDocument{
+		Length: len(terms),
+		Hash:   indexedHash(entry),
+		Terms:  make(map[string]int),
+	}
+	for _, t := range terms {
+		if index.Postings[t] == nil {
+			index.Postings[t] = make(map[string]int)
+		}
+		index.Postings[t][entry.ID]++
+		document.Terms[t]++
+	}
+
+	index.Documents[entry.ID] = document
+	index.changed = true
+}
+
+// remove an entry from the index
+func (index *searchIndex) remove(id string) {
+	document, ok := index.Documents[id]
+	if !ok {
+		return
+	}
+
+	for t := range document.Terms {
+		delete(index.Postings[t], id)
+		if len(index.Postings[t]) == 0 {
+			delete(index.Postings, t)
+		}
+	}
+	delete(index.Documents, id)
+	index.changed = true
+}
+
+// reindex the entries with the given IDs, added, changed or removed
+func (index *searchIndex) update(ids []string, entries []Entry) {
+	current := make(map[string]Entry)
+	for _, entry := range entries {
+		current[entry.ID] = entry
+	}
+	for _, id := range ids {
+		index.remove(id)
+		if entry, found := current[id]; found {
+			index.add(entry)
+		}
+	}
+}
+
+// update the index so that it reflects the entries, only reindexing the
+// entries that have been added or changed (e.g. by editing the file by hand)
+func (index *searchIndex) sync(entries []Entry) {
+	ids := make(map[string]bool)
+
+	for _, entry := range entries {
+		ids[entry.ID] = true
+		document, ok := index.Documents[entry.ID]
+		if ok && document.Hash == indexedHash(entry) {
+			continue
+		}
+		index.remove(entry.ID)
+		index.add(entry)
+	}
+
+	// remove the entries that are not in the journal anymore
+	for id := range index.Documents {
+		if !ids[id] {
+			index.remove(id)
+		}
+	}
+}
+
+// returns the unique terms of a search
+func searchTerms(keywords []string) (terms map[string]bool) {
+	terms = make(map[string]bool)
+	for _, k := range keywords {
+		for _, t := range tokenize(k) {
+			terms[t] = true
+		}
+	}
+	return terms
+}
+
+// search the entries matching any of the keywords, ranked by BM25
+func (index *searchIndex) search(keywords []string, entries []Entry) (results []searchResult) {
+	if len(index.Documents) == 0 {
+		return results
+	}
+
+	// average length of the entries
+	var totalLength int
+	for _, d := range index.Documents {
+		totalLength += d.Length
+	}
+	averageLength := float64(totalLength) / float64(len(index.Documents))
+	if averageLength == 0 {
+		averageLength = 1
+	}
+
+	scores := make(map[string]float64)
+	total := float64(len(index.Documents))
+	for t := range searchTerms(keywords) {
+		postings := index.Postings[t]
+		matching := float64(len(postings))
+		idf := math.Log(1 + (total-matching+0.5)/(matching+0.5))
+
+		for id, frequency := range postings {
+			f := float64(frequency)
+			length := float64(index.Documents[id].Length)
+			scores[id] += idf * f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*length/averageLength))
+		}
+	}
+
+	for _, entry := range entries {
+		if score, ok := scores[entry.ID]; ok {
+			results = append(results, searchResult{entry: entry, score: score})
+		}
+	}
+
+	// best results first, ties in chronological order
+	sort.SliceStable(results, func(i, k int) bool { return results[i].score > results[k].score })
+	return results
+}
+
+// returns the part of the text of the entry around the first match,
+// with the matching words passed to the highlight function
+func makeSnippet(entry Entry, terms map[string]bool, highlight func(string) string) string {
+	text := indexedText(entry)
+	spans := wordSpans(text)
+
+	first := -1
+	for i, s := range spans {
+		if terms[normalizeWord(text[s[0]:s[1]])] {
+			first = i
+			break
+		}
+	}
+	if first == -1 {
+		return ""
+	}
+
+	start := first - snippetBefore
+	if start < 0 {
+		start = 0
+	}
+	end := first + snippetAfter
+	if end > len(spans)-1 {
+		end = len(spans) - 1
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("...")
+	}
+	position := spans[start][0]
+	for _, s := range spans[start : end+1] {
+		b.WriteString(text[position:s[0]])
+		word := text[s[0]:s[1]]
+		if terms[normalizeWord(word)] {
+			word = highlight(word)
+		}
+		b.WriteString(word)
+		position = s[1]
+	}
+	if end < len(spans)-1 {
+		b.WriteString("...")
+	} else {
+		b.WriteString(text[position:])
+	}
+
+	return strings.TrimSpace(b.String())
+}
+
+// returns the path of the index file, next to the journal
+func (j *Journal) indexPath() string {
+	return j.folder + strings.TrimSuffix(j.filename, ".json") + ".index.json"
+}
+
+// load the search index saved next to the journal. Returns false if
+// there's no valid index on disk
+func (j *Journal) loadIndex() bool {
+	if j.index != nil {
+		return true
+	}
+
+	// encrypted journals never have an index on disk
+	file, e := readFromFile(j.indexPath())
+	if e != nil {
+		return false
+	}
+	var loaded searchIndex
+	e = json.Unmarshal(file, &loaded)
+	if e != nil || loaded.Version != indexVersion || loaded.Postings == nil || loaded.Documents == nil {
+		return false
+	}
+	j.index = &loaded
+	return true
+}
+
+// returns the search index, loading it and checking that it's up to date
+func (j *Journal) getIndex() *searchIndex {
+	if !j.loadIndex() {
+		j.index = newSearchIndex()
+	}
+	j.index.sync(j.Entries)
+	return j.index
+}
+
+// update the search index with the entries changed by a command. If there's
+// no index yet, it's built by the next search
+func (j *Journal) updateIndex(changes UndoState) {
+	if j.password != "" || !j.loadIndex() {
+		return
+	}
+
+	ids := append([]string{}, changes.Added...)
+	for _, entry := range changes.Entries {
+		ids = append(ids, entry.ID)
+	}
+	j.index.update(ids, j.Entries)
+}
+
+// save the search index next to the journal, if it changed
+func (j *Journal) saveIndex() (e error) {
+	if j.index == nil || !j.index.changed {
+		return nil
+	}
+	JSONbytes, e := json.Marshal(j.index)
+	if e != nil {
+		return errors.New("error while encoding the search index. cannot save")
+	}
+	return writeToFile(j.indexPath(), JSONbytes)
+}
+
+// remove the search index file
+func (j *Journal) removeIndex() {
+	os.Remove(j.indexPath())
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStem(t *testing.T) {
+	tests := map[string]string{
+		"run":      "run",
+		"runs":     "run",
+		"running":  "run",
+		"caresses": "caress",
+		"ponies":   "poni",
+		"cats":     "cat",
+		"agreed":   "agree",
+		"hoping":   "hope",
+		"happy":    "happi",
+		"sky":      "sky",
+		"is":       "is",
+		"café":     "café",
+	}
+	for word, want := range tests {
+		if got := stem(word); got != want {
+			t.Errorf("stem(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	got := tokenize("Running, in the PARK! Café-crème")
+	want := []string{"run", "in", "the", "park", "cafe", "creme"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tokenize = %q, want %q", got, want)
+	}
+}
+
+func TestSearchIndexUpdate(t *testing.T) {
+	entries := []Entry{
+		{ID: "a", Title: "Running in the park", Content: "A long run"},
+		{ID: "b", Title: "Skiing", Content: "Snow everywhere"},
+		{ID: "c", Title: "Reading", Content: "A book about running"},
+	}
+
+	index := newSearchIndex()
+	index.sync(entries)
+
+	// change one entry, remove another and add a new one
+	entries[0].Content = "A short walk"
+	entries = append(entries[:1], entries[2:]...)
+	entries = append(entries, Entry{ID: "d", Title: "Walking", Content: "In the snow"})
+	index.update([]string{"a", "b", "d"}, entries)
+
+	fresh := newSearchIndex()
+	fresh.sync(entries)
+	if !reflect.DeepEqual(index.Postings, fresh.Postings) || !reflect.DeepEqual(index.Documents, fresh.Documents) {
+		t.Errorf("updated index differs from a fresh one:\n%+v\n%+v", index, fresh)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	entries := []Entry{
+		{ID: "a", Title: "Reading", Content: "A book about running"},
+		{ID: "b", Title: "Running", Content: "Ran and ran, running all day long: a great run"},
+		{ID: "c", Title: "Skiing", Content: "Snow"},
+	}
+	index := newSearchIndex()
+	index.sync(entries)
+
+	var ids []string
+	for _, r := range index.search([]string{"runs"}, entries) {
+		ids = append(ids, r.entry.ID)
+	}
+	if want := []string{"b", "a"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("search(runs) = %v, want %v", ids, want)
+	}
+	if results := index.search([]string{"swimming"}, entries); len(results) != 0 {
+		t.Errorf("search(swimming) = %v, want no results", results)
+	}
+}
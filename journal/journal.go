@@ -4,6 +4,7 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -19,24 +20,47 @@ import (
 
 // Entry contains a single entry in the journal
 type Entry struct {
+	ID        string            `json:"id"`
 	Title     string            `json:"title"`
 	Content   string            `json:"content"`
 	Timestamp string            `json:"timestamp"`
 	Tags      []string          `json:"tags"`
 	Fields    map[string]string `json:"fields"`
-	timeObj   time.Time
+	// last time the entry was changed
+	Updated string `json:"updated,omitempty"`
+	timeObj time.Time
 }
 
 // Journal is the class containing the whole journal
 type Journal struct {
-	Entries          []Entry `json:"days"`
-	LastLoaded       string  `json:"LastLoaded"`
-	Created          string  `json:"created"`
-	Version          string  `json:"version"`
+	Entries    []Entry `json:"days"`
+	LastLoaded string  `json:"LastLoaded"`
+	Created    string  `json:"created"`
+	Version    string  `json:"version"`
+	// alias -> tag that replaces it when adding entries
+	TagAliases map[string]string `json:"tagAliases,omitempty"`
+	// rules for the fields of the entries
+	FieldSchema FieldSchema `json:"fieldSchema,omitempty"`
+	// show the entries from the past once a day
+	DailyOnThisDay bool `json:"dailyOnThisDay,omitempty"`
+	// day when the entries from the past were last shown
+	LastOnThisDay string `json:"lastOnThisDay,omitempty"`
+	// how far back to look in the "on this day" view (e.g. 1w, 1m)
+	// an empty list disables them, no list means the default ones
+	Lookbacks *[]string `json:"lookbacks,omitempty"`
+	// entries removed, kept for TrashRetention days
+	Trash []TrashedEntry `json:"trash,omitempty"`
+	// days the removed entries are kept in the trash
+	TrashRetention int `json:"trashRetention,omitempty"`
+	// state before the last change, restored by --undo
+	Undo *UndoState `json:"undo,omitempty"`
+	// entry ID -> previous versions of the entry, oldest first
+	History          map[string][]Revision `json:"history,omitempty"`
 	repo             string
 	password         string
 	folder, filename string
 	timeFormat       string
+	index            *searchIndex
 }
 
 //SetPassword -> sets new database password
@@ -120,6 +144,37 @@ func writeToFile(path string, bytes []byte) (e error) {
 	return e
 }
 
+// generate a new random ID, unique in the journal
+func (j *Journal) newEntryID() (id string) {
+	bytes := make([]byte, 4)
+	for {
+		rand.Read(bytes)
+		id = hex.EncodeToString(bytes)
+		if _, found := j.findEntryByID(id); !found {
+			return id
+		}
+	}
+}
+
+// assign an ID to every entry that doesn't have one
+func (j *Journal) fillEntryIDs() {
+	for i := 0; i < len(j.Entries); i++ {
+		if j.Entries[i].ID == "" {
+			j.Entries[i].ID = j.newEntryID()
+		}
+	}
+}
+
+// find the position of an entry given its ID
+func (j *Journal) findEntryByID(id string) (position int, found bool) {
+	for i, entry := range j.Entries {
+		if entry.ID == id {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
 // package the variables into a new entry
 func (j *Journal) createNewEntry(title, content string, tags []string, fields map[string]string, timeObj time.Time) (entry Entry) {
 	var timestamp string
@@ -127,6 +182,7 @@ func (j *Journal) createNewEntry(title, content string, tags []string, fields ma
 	timestamp = timeObj.Format(j.timeFormat)
 	// create the new entry
 	entry = Entry{
+		ID:        j.newEntryID(),
 		Title:     title,
 		Content:   content,
 		Tags:      tags,
@@ -159,9 +215,9 @@ func (j *Journal) load() (e error) {
 	}
 
 	// calculate the time for each entry
-	for i := 0; i < len(j.Entries); i++ {
-		j.Entries[i].timeObj, _ = time.Parse(j.timeFormat, j.Entries[i].Timestamp)
-	}
+	j.parseEntriesTime()
+	// entries saved by older versions don't have an ID
+	j.fillEntryIDs()
 
 	// update last loaded
 	j.LastLoaded = time.Now().Format(time.RFC3339)
@@ -211,9 +267,9 @@ func (j *Journal) decrypt() (e error) {
 		return errors.New("cannot parse database")
 	}
 	// calculate the time for each entry
-	for i := 0; i < len(j.Entries); i++ {
-		j.Entries[i].timeObj, _ = time.Parse(j.timeFormat, j.Entries[i].Timestamp)
-	}
+	j.parseEntriesTime()
+	// entries saved by older versions don't have an ID
+	j.fillEntryIDs()
 	// update last loaded
 	j.LastLoaded = time.Now().Format(time.RFC3339)
 
@@ -228,8 +284,12 @@ func (j *Journal) save() (e error) {
 		return errors.New("error while encoding data. cannot save")
 	}
 	// write to file
-	writeToFile(j.folder+j.filename, JSONbytes)
-	return e
+	e = writeToFile(j.folder+j.filename, JSONbytes)
+	if e != nil {
+		return e
+	}
+	// keep the search index up to date
+	return j.saveIndex()
 }
 
 func (j *Journal) encrypt() (e error) {
@@ -258,11 +318,16 @@ func (j *Journal) encrypt() (e error) {
 	ciphertext := gcm.Seal(nonce, nonce, JSONbytes, nil)
 	// write to file
 	e = writeToFile(j.folder+j.filename, ciphertext)
+	if e != nil {
+		return e
+	}
+	// the index would leak the content of the journal
+	j.removeIndex()
 	return e
 }
 
 // create a new entry
-func (j *Journal) createEntry(entry string) {
+func (j *Journal) createEntry(entry string) (e error) {
 	// array of separators that end the title
 	var delimiters = []string{".", "?", "!", "+", "@"}
 	var currentDelimiter string
@@ -272,7 +337,6 @@ func (j *Journal) createEntry(entry string) {
 	var tags []string
 	// fields variable
 	var fields map[string]string
-	fields = make(map[string]string)
 	// current datetime variable
 	var newDate time.Time
 	// new entry variable
@@ -281,7 +345,7 @@ func (j *Journal) createEntry(entry string) {
 	// find the submitted date and the entry without the (eventual) date
 	content, newDate = parseEntry(entry)
 	if content == "" {
-		return
+		return nil
 	}
 
 	// find the delimiter between title and content
@@ -298,6 +362,29 @@ func (j *Journal) createEntry(entry string) {
 		content = strings.Replace(content, title, "", 1)
 	}
 
+	// load the tags and the fields
+	content, tags, fields = j.extractTagsAndFields(content)
+
+	// check the fields against the schema of the journal
+	if problems := j.FieldSchema.validate(tags, fields); len(problems) > 0 {
+		return joinErrors(problems)
+	}
+
+	// finally, generate the new entry
+	newEntry = j.createNewEntry(title, content, tags, fields, newDate)
+	// append the entry to the entries array
+	j.Entries = append(j.Entries, newEntry)
+	// sort the entries array
+	sort.Slice(j.Entries, func(i, k int) bool { return j.Entries[i].timeObj.Before(j.Entries[k].timeObj) })
+	return nil
+}
+
+// extract the +tags and the @fields from the text of an entry
+// returns the text without them
+func (j *Journal) extractTagsAndFields(text string) (content string, tags []string, fields map[string]string) {
+	content = text
+	fields = make(map[string]string)
+
 	// now load the tags
 	if strings.Contains(content, "+") {
 		// we found one or more tags
@@ -312,6 +399,15 @@ func (j *Journal) createEntry(entry string) {
 			tags[i] = strings.TrimSpace(tags[i])
 			content = strings.ReplaceAll(content, "+"+tags[i], "")
 		}
+
+		// remove the empty levels of hierarchical tags
+		var cleanTags []string
+		for _, t := range tags {
+			if t = cleanTag(t); t != "" {
+				cleanTags = append(cleanTags, t)
+			}
+		}
+		tags = j.applyTagAliases(cleanTags)
 	}
 
 	// now load the fields
@@ -340,12 +436,76 @@ func (j *Journal) createEntry(entry string) {
 	// remove all multiple spaces
 	content = removeMultipleSpaces(content)
 
-	// finally, generate the new entry
-	newEntry = j.createNewEntry(title, content, tags, fields, newDate)
-	// append the entry to the entries array
-	j.Entries = append(j.Entries, newEntry)
-	// sort the entries array
-	sort.Slice(j.Entries, func(i, k int) bool { return j.Entries[i].timeObj.Before(j.Entries[k].timeObj) })
+	return content, tags, fields
+}
+
+// append text, tags and fields to an existing entry, chosen by its ID,
+// by "today" (the last entry of today) or by "last" (the last entry)
+func (j *Journal) appendToEntry(target, text string) (entry Entry, e error) {
+	position := -1
+	switch strings.ToLower(target) {
+	case "last":
+		// entries are sorted by time
+		position = len(j.Entries) - 1
+	case "today":
+		for i := range j.Entries {
+			if sameDay(j.Entries[i].timeObj, time.Now()) {
+				position = i
+			}
+		}
+	default:
+		position, _ = j.findEntryByID(target)
+	}
+	if position == -1 {
+		switch strings.ToLower(target) {
+		case "last":
+			return Entry{}, errors.New("the journal has no entries")
+		case "today":
+			return Entry{}, errors.New("no entries written today")
+		}
+		return Entry{}, errors.New("cannot find entry with id " + target)
+	}
+
+	content, tags, fields := j.extractTagsAndFields(text)
+	if content == "" && len(tags) == 0 && len(fields) == 0 {
+		return Entry{}, errors.New("nothing to append")
+	}
+
+	// copy the entry, so that nothing changes if it's not valid
+	entry = j.Entries[position]
+	entry.Tags = append([]string{}, entry.Tags...)
+	for _, t := range tags {
+		found := false
+		for _, existing := range entry.Tags {
+			found = found || existing == t
+		}
+		if !found {
+			entry.Tags = append(entry.Tags, t)
+		}
+	}
+	newFields := make(map[string]string)
+	for k, v := range entry.Fields {
+		newFields[k] = v
+	}
+	for k, v := range fields {
+		newFields[k] = v
+	}
+	entry.Fields = newFields
+
+	// check the fields against the schema of the journal
+	if problems := j.FieldSchema.validate(entry.Tags, entry.Fields); len(problems) > 0 {
+		return Entry{}, joinErrors(problems)
+	}
+
+	if content != "" {
+		if entry.Content != "" {
+			entry.Content += "\n"
+		}
+		entry.Content += content
+	}
+	entry.Updated = time.Now().Format(j.timeFormat)
+	j.Entries[position] = entry
+	return entry, nil
 }
 
 func (j *Journal) removeEntry(timestamp string) (e error) {
@@ -367,7 +527,9 @@ func (j *Journal) removeEntry(timestamp string) (e error) {
 
 		switch level {
 		case 0:
-			return errors.New("don't provide date with this flag")
+			if !sameMinute(e.timeObj, removeDate) {
+				cleanEntries = append(cleanEntries, e)
+			}
 		case 1:
 			if !sameDay(e.timeObj, removeDate) {
 				cleanEntries = append(cleanEntries, e)
@@ -387,12 +549,27 @@ func (j *Journal) removeEntry(timestamp string) (e error) {
 		// no entries were removed
 		return errors.New("entry not found")
 	}
+	// move the removed entries to the trash
+	j.trashEntries(removedEntries(j.Entries, cleanEntries))
 	// replace the entries with a new slice
 	j.Entries = cleanEntries
 	return nil
 
 }
 
+// remove a single entry, given its ID
+func (j *Journal) removeEntryByID(id string) (e error) {
+	position, found := j.findEntryByID(id)
+	if !found {
+		return errors.New("entry not found")
+	}
+
+	// move the removed entry to the trash
+	j.trashEntries([]Entry{j.Entries[position]})
+	j.Entries = append(j.Entries[:position], j.Entries[position+1:]...)
+	return nil
+}
+
 func (j *Journal) showEntries(timestamp string) (entries []Entry, e error) {
 	var getDate time.Time
 	var level int
@@ -403,7 +580,9 @@ func (j *Journal) showEntries(timestamp string) (entries []Entry, e error) {
 	for _, e := range j.Entries {
 		switch level {
 		case 0:
-			return entries, errors.New("don't provide date with this flag")
+			if sameMinute(e.timeObj, getDate) {
+				entries = append(entries, e)
+			}
 		case 1:
 			if sameDay(e.timeObj, getDate) {
 				entries = append(entries, e)
@@ -462,6 +641,8 @@ func (j *Journal) removeEntriesBetween(startTimestamp, endTimestamp string) (e e
 		// no entries were removed
 		return errors.New("entries not found")
 	}
+	// move the removed entries to the trash
+	j.trashEntries(removedEntries(j.Entries, cleanEntries))
 	// replace the entries with a new slice
 	j.Entries = cleanEntries
 	return nil
@@ -469,6 +650,7 @@ func (j *Journal) removeEntriesBetween(startTimestamp, endTimestamp string) (e e
 }
 
 func (j *Journal) removeAllEntries() {
+	j.trashEntries(j.Entries)
 	j.Entries = make([]Entry, 0)
 }
 
@@ -496,32 +678,21 @@ func (j *Journal) getEntriesBetween(startTimestamp, endTimestamp string) (entrie
 
 }
 
-func (j *Journal) searchKeywords(keywords []string) (entries []Entry, e error) {
-	for _, entry := range j.Entries {
-		for _, k := range keywords {
-			if strings.Contains(entry.Title, k) || strings.Contains(entry.Content, k) {
-				entries = append(entries, entry)
-			}
-		}
-	}
+func (j *Journal) searchKeywords(keywords []string) (results []searchResult, e error) {
+	results = j.getIndex().search(keywords, j.Entries)
 
-	if len(entries) > 0 {
-		return entries, nil
+	if len(results) > 0 {
+		return results, nil
 	}
-	return make([]Entry, 0), errors.New("no entries found with the keyword")
+	return make([]searchResult, 0), errors.New("no entries found with the keyword")
 
 }
 
 func (j *Journal) searchTags(tags []string) ([]Entry, error) {
 	var entries []Entry
 	for _, entry := range j.Entries {
-		for _, entryTag := range entry.Tags {
-			for _, t := range tags {
-				if entryTag == t {
-					entries = append(entries, entry)
-					break
-				}
-			}
+		if entryHasAnyTag(entry, tags) {
+			entries = append(entries, entry)
 		}
 	}
 
@@ -548,11 +719,32 @@ func (j *Journal) searchFields(keys []string) (entries []Entry, e error) {
 
 }
 
+// check if an entry has any of the tags (or one of their descendants)
+func entryHasAnyTag(entry Entry, tags []string) bool {
+	for _, entryTag := range entry.Tags {
+		for _, t := range tags {
+			if tagMatches(entryTag, t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// count the entries using each tag
+// parent tags also count the entries using their descendants
 func (j *Journal) getAllTags() (tags map[string]int, e error) {
 	tags = make(map[string]int)
 	for _, entry := range j.Entries {
+		// each entry is counted only once per tag
+		counted := make(map[string]bool)
 		for _, tag := range entry.Tags {
-			tags[tag]++
+			for _, t := range tagAncestors(tag) {
+				if !counted[t] {
+					counted[t] = true
+					tags[t]++
+				}
+			}
 		}
 	}
 
@@ -563,16 +755,13 @@ func (j *Journal) getAllTags() (tags map[string]int, e error) {
 
 }
 
-func (j *Journal) getAllFields() (fields []map[string]string, e error) {
-	for _, entry := range j.Entries {
-		if len(entry.Fields) > 0 {
-			fields = append(fields, entry.Fields)
-		}
-	}
+// summarize the fields used in the entries
+func (j *Journal) getAllFields(entries []Entry) (fields []fieldSummary, e error) {
+	fields = summarizeFields(entries)
 
 	if len(fields) > 0 {
 		return fields, nil
 	}
-	return make([]map[string]string, 0), errors.New("no fields found")
+	return make([]fieldSummary, 0), errors.New("no fields found")
 
 }
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// returns the path of the file of the journal
+func (j *Journal) path() string {
+	return j.folder + j.filename
+}
+
+// returns the time of the last change of the journal file
+func (j *Journal) modTime() time.Time {
+	if info, e := os.Stat(j.path()); e == nil {
+		return info.ModTime()
+	}
+	return time.Time{}
+}
+
+// open another journal, given its name (as in --use) or the path of its file.
+// Names are looked up in the journal folder first: the argument is a path only
+// if it contains a separator or ends in .json. If the journal is encrypted, its
+// password is asked. If create is true and the journal doesn't exist, it's
+// created with the password of this journal
+func (j *Journal) openJournal(name string, create bool) (other *Journal, e error) {
+	other = &Journal{
+		Version:    j.Version,
+		repo:       j.repo,
+		timeFormat: j.timeFormat,
+		folder:     j.folder,
+		filename:   name,
+	}
+	if !strings.HasSuffix(name, ".json") {
+		other.filename += ".json"
+	}
+
+	isFile := func(path string) bool {
+		info, e := os.Stat(path)
+		return e == nil && !info.IsDir()
+	}
+	found := isFile(j.folder + other.filename)
+	if strings.ContainsAny(name, "/"+string(os.PathSeparator)) || (!found && strings.HasSuffix(name, ".json")) {
+		// the file of the journal, outside of the journal folder
+		other.folder = filepath.Dir(name) + string(os.PathSeparator)
+		other.filename = filepath.Base(name)
+		found = isFile(name)
+	}
+	if !found {
+		if !create {
+			return nil, errors.New("cannot find journal " + name)
+		}
+		// load creates the empty journal
+		other.SetPassword(j.password)
+		return other, other.load()
+	}
+
+	// opening the same file twice would lose the changes of one of them
+	thisPath, _ := filepath.Abs(j.path())
+	otherPath, _ := filepath.Abs(other.path())
+	if thisPath == otherPath {
+		return nil, errors.New("the journal " + other.filename + " is the one in use")
+	}
+
+	if e := other.load(); e == nil {
+		return other, nil
+	}
+
+	// the journal might be encrypted
+	password, e := getPassword("Password of " + other.filename + ":")
+	if e != nil {
+		return nil, e
+	}
+	other.SetPassword(password)
+	if e := other.decrypt(); e != nil {
+		return nil, errors.New("cannot open journal " + other.filename + ": " + e.Error())
+	}
+	return other, nil
+}
+
+// save the journal, encrypting it if it has a password
+func (j *Journal) write() (e error) {
+	if j.password != "" {
+		return j.encrypt()
+	}
+	return j.save()
+}
+
+// get the entries selected by an ID, by a date (YYYY-MM-DD, YYYY-MM or YYYY)
+// or by a query, optionally between two dates
+func (j *Journal) selectEntries(selector, query, startTimestamp, endTimestamp string) (entries []Entry, e error) {
+	if selector != "" {
+		if position, found := j.findEntryByID(selector); found {
+			return []Entry{j.Entries[position]}, nil
+		}
+		if _, level := parseDay(selector); level == -1 {
+			return make([]Entry, 0), errors.New("'" + selector + "' is neither an entry ID nor a date")
+		}
+		return j.showEntries(selector)
+	}
+
+	// moving the whole journal by mistake is too easy
+	if query == "" && startTimestamp == "" && endTimestamp == "" {
+		return make([]Entry, 0), errors.New("no entries selected. Provide an ID, a date or a query")
+	}
+	return j.queryEntries(query, startTimestamp, endTimestamp)
+}
+
+// copy entries to another journal and save it. If move is true, the entries are
+// then removed from this journal. Entries already in the other journal are
+// skipped, entries whose ID is used by a different entry get a new one
+func (j *Journal) transferEntries(entries []Entry, target *Journal, move bool) (transferred, skipped int, e error) {
+	var copies []Entry
+	for _, entry := range entries {
+		if _, found := target.findEntryByID(entry.ID); found && !target.hasDuplicate(entry) {
+			entry.ID = ""
+		}
+		copies = append(copies, entry)
+	}
+
+	if transferred, skipped, e = target.importEntries(copies); e != nil {
+		return 0, 0, e
+	}
+	// the entries are removed only once they are safe in the other journal
+	if e := target.write(); e != nil {
+		return 0, 0, errors.New("cannot save journal " + target.filename + ": " + e.Error())
+	}
+
+	if move {
+		selected := make(map[string]bool)
+		for _, entry := range entries {
+			selected[entry.ID] = true
+		}
+		cleanEntries := make([]Entry, 0)
+		for _, entry := range j.Entries {
+			if !selected[entry.ID] {
+				cleanEntries = append(cleanEntries, entry)
+			}
+		}
+		j.Entries = cleanEntries
+	}
+	return transferred, skipped, nil
+}
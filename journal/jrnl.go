@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// entry of a jrnl JSON export
+type jrnlEntry struct {
+	Title   string   `json:"title"`
+	Body    string   `json:"body"`
+	Date    string   `json:"date"`
+	Time    string   `json:"time"`
+	Tags    []string `json:"tags"`
+	Starred bool     `json:"starred"`
+}
+
+// jrnl JSON export
+type jrnlExport struct {
+	Entries []jrnlEntry `json:"entries"`
+}
+
+var (
+	// first line of an entry in a jrnl plaintext export (e.g. [2021-03-01 10:00] Title)
+	jrnlEntryLine = regexp.MustCompile(`^\[(\d{4}-\d{2}-\d{2} [^\]]+)\] ?(.*)$`)
+	// tag in the text of a jrnl entry (e.g. @work)
+	jrnlTag = regexp.MustCompile(`(?:^|\s)@([\p{L}\p{N}_/-]+)`)
+)
+
+// layouts of the timestamps of jrnl
+var jrnlTimestampLayouts = []string{"2006-01-02 15:04", "2006-01-02 15:04:05", "2006-01-02 03:04 PM", "2006-01-02 3:04 PM", "2006-01-02 03:04:05 PM"}
+
+// parses a jrnl timestamp
+func parseJrnlTimestamp(value string) (timeObj time.Time, e error) {
+	for _, layout := range jrnlTimestampLayouts {
+		if timeObj, e = time.Parse(layout, strings.TrimSpace(value)); e == nil {
+			return timeObj, nil
+		}
+	}
+	return time.Time{}, errors.New("cannot parse timestamp '" + value + "'")
+}
+
+// splits the text of an entry in title and content at the end
+// of the first sentence or line, like jrnl does
+func splitTitle(text string) (title, content string) {
+	text = strings.TrimSpace(text)
+	end := len(text)
+	if i := strings.Index(text, "\n"); i != -1 {
+		end = i
+	}
+	for _, delimiter := range []string{". ", "? ", "! "} {
+		if i := strings.Index(text, delimiter); i != -1 && i+1 < end {
+			end = i + 1
+		}
+	}
+	return strings.TrimSpace(text[:end]), strings.TrimSpace(text[end:])
+}
+
+// reads the entries of a jrnl JSON export
+func parseJrnlJSON(data []byte) (entries []Entry, e error) {
+	var export jrnlExport
+	if e := json.Unmarshal(data, &export); e != nil {
+		return nil, errors.New("cannot parse jrnl export: " + e.Error())
+	}
+
+	for _, j := range export.Entries {
+		timeObj, e := parseJrnlTimestamp(j.Date + " " + j.Time)
+		if e != nil {
+			return nil, e
+		}
+		entries = append(entries, Entry{
+			Title:   strings.TrimSpace(j.Title),
+			Content: strings.TrimSpace(j.Body),
+			Tags:    importedTags(j.Tags, j.Starred),
+			timeObj: timeObj,
+		})
+	}
+
+	if len(entries) == 0 {
+		return nil, errors.New("no entries found in file")
+	}
+	return entries, nil
+}
+
+// reads the entries of a jrnl plaintext export or journal file.
+// Each entry starts with its timestamp in brackets, starred entries
+// end their first line with an asterisk
+func parseJrnlText(data []byte) (entries []Entry, e error) {
+	var timeObj time.Time
+	var lines []string
+	starred := false
+
+	// add the current entry to the list
+	finish := func() {
+		if timeObj.IsZero() {
+			return
+		}
+		text := strings.Join(lines, "\n")
+		var tags []string
+		for _, match := range jrnlTag.FindAllStringSubmatch(text, -1) {
+			tags = append(tags, match[1])
+		}
+		title, content := splitTitle(text)
+		entries = append(entries, Entry{Title: title, Content: content, Tags: importedTags(tags, starred), timeObj: timeObj})
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		match := jrnlEntryLine.FindStringSubmatch(line)
+		if match == nil {
+			lines = append(lines, line)
+			continue
+		}
+
+		parsed, e := parseJrnlTimestamp(match[1])
+		if e != nil {
+			// not an entry: the line is part of the text
+			lines = append(lines, line)
+			continue
+		}
+
+		finish()
+		timeObj, lines = parsed, nil
+		first := strings.TrimSpace(match[2])
+		starred = strings.HasSuffix(first, " *") || first == "*"
+		if starred {
+			first = strings.TrimSpace(strings.TrimSuffix(first, "*"))
+		}
+		lines = append(lines, first)
+	}
+	finish()
+
+	if len(entries) == 0 {
+		return nil, errors.New("no entries found in file")
+	}
+	return entries, nil
+}
+
+// reads the entries of a jrnl export, either JSON or plaintext
+func parseJrnlEntries(data []byte) ([]Entry, error) {
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "{") {
+		return parseJrnlJSON(data)
+	}
+	return parseJrnlText(data)
+}
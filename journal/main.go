@@ -7,27 +7,70 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"os"
 	"strings"
-
-	"github.com/lorossi/colorize"
+	"time"
 )
 
 func main() {
 	// flags list
 	version := flag.Bool("version", false, "show current version")
 	use := flag.String("use", "", "use a journal that's not the default one")
-	add := flag.String("add", "", "add an entry to the journal. Date format: today, yesterday, weekday (monday-sunday) YYYY-MM-DD, YYYY-MM-DD. You can also set a time in format hh.mm")
-	remove := flag.String("remove", "", "remove an entry from the journal. Date format: YYYY-MM-DD or YYYY-MM or YYYY")
-	show := flag.String("show", "", "show entries from the journal. Use all to see all. Date format: YYYY-MM-DD or YYYY-MM or YYYY")
+	add := flag.String("add", "", "add an entry to the journal. Date format: today, yesterday, weekday (monday-sunday) YYYY-MM-DD, YYYY-MM-DD. You can also set a time in format hh.mm, hh:mm, 7am, 7:30pm or at noon, at morning, at afternoon, at evening, at night")
+	appendto := flag.String("append", "", "append text, +tags and @fields to an entry. Pass the ID of the entry, today (the last entry of today) or last (the last entry). Usage: --append last text")
+	remove := flag.String("remove", "", "remove an entry from the journal. Date format: YYYY-MM-DD or YYYY-MM or YYYY. Add a time (hh.mm) to remove the entry written in that minute")
+	show := flag.String("show", "", "show entries from the journal. Use all to see all. Date format: YYYY-MM-DD or YYYY-MM or YYYY. Add a time (hh.mm) to see the entries written in that minute")
 	searchkeywords := flag.String("search", "", "search entries by text (both in title and content)")
 	searchtags := flag.String("searchtags", "", "search entries by tags")
 	searchfields := flag.String("searchfields", "", "search entries by fields")
+	regex := flag.Bool("regex", false, "search entries with a regular expression (RE2 syntax) in title, content and fields. Must be used with --search")
+	fuzzy := flag.Bool("fuzzy", false, "search entries with words similar to the keywords, tolerating typos. Must be used with --search")
+	chart := flag.String("chart", "", "show a chart of a numeric field. Can be used with --by, --aggregate, --charttype, --from, --to and --query")
+	aggregate := flag.String("aggregate", "mean", "aggregation of the values in each period of a chart. Values: sum, mean, min, max, count")
+	charttype := flag.String("charttype", "bar", "type of chart. Values: bar, line, sparkline")
+	onthisday := flag.Bool("onthisday", false, "show the entries written on this day in the previous years and some time ago. Optionally pass a date")
+	lookbacks := flag.String("lookbacks", "", "set how far back to look with --onthisday, separated by commas. Format: 1d, 2w, 6m, 1y. Use none to disable")
+	dailyonthisday := flag.String("dailyonthisday", "", "show the entries from the past the first time the journal is opened each day. Values: on, off")
+	review := flag.String("review", "", "show a review of a week, month or year compared with the previous one. Optionally pass a date in the period. Values: week, month, year. Can be used with --query and --markdown")
+	calendar := flag.String("calendar", "", "show a calendar of a month (YYYY-MM) or a year (YYYY) marking the days with entries. Can be used with --field and --query")
+	stats := flag.Bool("stats", false, "show statistics about the entries. Can be filtered with --from, --to and --query")
+	query := flag.String("query", "", "search entries with a query. Example: tag:work AND NOT tag:meeting AND @run>5 AND date:2021-03..2021-06 AND \"deploy\"")
+	export := flag.String("export", "", "export the entries to a folder (markdown) or to a file (csv, tsv, ics, org). Can be used with --format, --by, --from, --to and --query")
+	importfile := flag.String("import", "", "import the entries of a file. Can be used with --format and --columns")
+	format := flag.String("format", "", "format of the exported or imported entries. If not provided, the extension of the file is used (markdown for folders). Values: markdown, csv, tsv, ics, org. Imports also accept jrnl and dayone")
+	allday := flag.Bool("allday", false, "export the entries as all-day events instead of journal entries. Only valid with --export in ics format")
+	columns := flag.String("columns", "", "map the columns of an imported csv or tsv file to the entries. Format: column=target,column=target. Targets: id, timestamp, title, content, tags, @field, - (ignore)")
+	merge := flag.String("merge", "", "merge the entries of another journal (name or path of the file) into this one. Can be used with --strategy and --dryrun")
+	strategy := flag.String("strategy", "both", "how to solve the conflicts when merging. Values: both (keep both versions), newer (keep the version changed last), interactive")
+	trash := flag.String("trash", "", "manage the removed entries. Values: list, restore (followed by the IDs of the entries or all), empty")
+	retention := flag.Int("retention", 0, "set the number of days the removed entries are kept in the trash. Default: 30")
+	undo := flag.Bool("undo", false, "undo the last change to the journal")
+	yes := flag.Bool("yes", false, "don't ask for confirmation before removing multiple entries or emptying the trash")
+	history := flag.String("history", "", "show the previous versions of an entry, given its ID, with the changes between them")
+	revert := flag.String("revert", "", "restore a previous version of an entry. Usage: --revert id number")
+	move := flag.String("move", "", "move entries to another journal (name or path of the file). Select the entries with an ID, a date (YYYY-MM-DD, YYYY-MM, YYYY) or --query, --from and --to. Usage: --move journal id")
+	copyto := flag.String("copy", "", "copy entries to another journal (name or path of the file). Select the entries with an ID, a date (YYYY-MM-DD, YYYY-MM, YYYY) or --query, --from and --to. Usage: --copy journal id")
+	publish := flag.String("publish", "", "build a static website from the entries in a folder. Entries tagged private or sealed are excluded. Can be used with --theme, --from, --to and --query")
+	theme := flag.String("theme", "", "folder containing the theme used by --publish. If not provided, the default theme is used")
 	printPlaintext := flag.Bool("plaintext", false, "show as plaintext")
 	printJSON := flag.Bool("json", false, "show as json")
+	printMarkdown := flag.Bool("markdown", false, "show as markdown. Only valid if passed with --review")
 	tags := flag.Bool("tags", false, "show all used tags")
-	fields := flag.Bool("fields", false, "show all used fields")
-	from := flag.String("from", "", "starting date. Only valied if passed with --show, --search or --remove flags and \"all\" argument. Format: YYYY-MM-DD")
-	to := flag.String("to", "", "ending date. Only valied if passed with --show, --search or --remove flag and \"all\" argument. Format: YYYY-MM-DD")
+	renametag := flag.String("renametag", "", "rename a tag and all of its descendants. Usage: --renametag old new")
+	mergetags := flag.String("mergetags", "", "merge a tag and all of its descendants into another tag. Usage: --mergetags from to")
+	deletetag := flag.String("deletetag", "", "remove a tag and all of its descendants from every entry")
+	aliastag := flag.String("aliastag", "", "replace a tag with another one when adding entries. Usage: --aliastag alias tag")
+	removealias := flag.String("removealias", "", "remove a tag alias")
+	aliases := flag.Bool("aliases", false, "show all tag aliases")
+	dryrun := flag.Bool("dryrun", false, "show the entries that would be changed without changing them. Only valid if passed with --renametag, --mergetags, --deletetag, --aliastag or --merge")
+	fields := flag.Bool("fields", false, "show all used fields with their values")
+	field := flag.String("field", "", "aggregate the values of a field over time. Use with --by")
+	by := flag.String("by", "day", "period used to aggregate. Values: day, week, month, year. When exporting, one file is written for each period (day or month)")
+	setschema := flag.String("setschema", "", "set the field schema of the journal from a JSON file. Use none to remove it")
+	schema := flag.Bool("schema", false, "show the field schema of the journal")
+	lintfields := flag.Bool("lintfields", false, "show the fields that don't follow the schema of the journal")
+	from := flag.String("from", "", "starting date. Only valied if passed with --show, --search, --query or --remove flags and \"all\" argument. Format: YYYY-MM-DD")
+	to := flag.String("to", "", "ending date. Only valied if passed with --show, --search, --query or --remove flag and \"all\" argument. Format: YYYY-MM-DD")
 	encrypt := flag.Bool("encrypt", false, "encrypt journal using AES")
 	decrypt := flag.Bool("decrypt", false, "decrypt using AES")
 	removePassword := flag.Bool("removepassword", false, "permanently decrypt a journal by removing its password")
@@ -80,7 +123,7 @@ func main() {
 			return
 		}
 
-		fmt.Println(colorize.BrightGreen("Database decrypted"))
+		fmt.Println(brightGreen("Database decrypted"))
 	} else {
 		e := j.load()
 		if e != nil {
@@ -89,31 +132,121 @@ func main() {
 		}
 	}
 
+	// the entries removed long ago are deleted permanently
+	j.purgeTrash(time.Now())
+	// state of the journal before the command, to undo it
+	before := j.snapshot()
+	// true if the command changed another journal too
+	otherChanged := false
+
+	// show the entries from the past, once a day. Output read by
+	// other programs or written as plaintext, JSON or Markdown is left alone
+	if j.DailyOnThisDay && !j.onThisDayShownToday() && isTerminal() && !(*printPlaintext || *printJSON || *printMarkdown) {
+		j.LastOnThisDay = time.Now().Format("2006-01-02")
+		if groups, e := j.onThisDay(time.Now()); e == nil {
+			fmt.Print(brightGreen("\nOn this day"))
+			printRetrospective(groups, false, false)
+		}
+	}
+
 	// no commands were provided but some text was recognized
 	if flag.NFlag() == 0 && flag.NArg() > 0 {
 		entry := strings.Join(flag.Args(), " ")
-		j.createEntry(entry)
+		if e := j.createEntry(entry); e != nil {
+			printError(e, 2)
+		}
+	} else if (*regex || *fuzzy) && *searchkeywords == "" {
+		// they would be ignored by any other command
+		printError(errors.New("--regex and --fuzzy must be used with --search"), 2)
 	} else if *add != "" {
 		// get text provided by the flag
 		// get remainder text
 		// concantenate them
 		entry := string(*add) + " " + strings.Join(flag.Args(), " ")
-		j.createEntry(entry)
+		if e := j.createEntry(entry); e != nil {
+			printError(e, 2)
+		}
+	} else if *appendto != "" {
+		if entry, e := j.appendToEntry(*appendto, strings.Join(flag.Args(), " ")); e != nil {
+			printError(e, 2)
+		} else {
+			fmt.Println(brightGreen("Text appended to " + entry.Title))
+		}
 	} else if *remove != "" {
 		var e error
+		trashed := len(j.Trash)
 		if *remove == "all" {
 			if *from != "" && *to != "" {
-				e = j.removeEntriesBetween(*from, *to)
+				if entries, _ := j.getEntriesBetween(*from, *to); confirmRemoval(len(entries), *yes) {
+					e = j.removeEntriesBetween(*from, *to)
+				}
 			} else if *from == "" && *to == "" {
-				j.removeAllEntries()
+				if confirmRemoval(len(j.Entries), *yes) {
+					j.removeAllEntries()
+				}
 			} else {
 				printError(errors.New("wrong parameter with remove flag"), 2)
 			}
+		} else if timestamp := strings.Join(append([]string{*remove}, flag.Args()...), " "); isMinute(timestamp) {
+			// several entries can be written in the same minute
+			var entries []Entry
+			if entries, e = j.showEntries(timestamp); len(entries) > 1 {
+				entries = chooseEntry(entries)
+			}
+			if confirmRemoval(len(entries), *yes) {
+				for _, entry := range entries {
+					if e = j.removeEntryByID(entry.ID); e != nil {
+						break
+					}
+				}
+			}
 		} else {
-			e = j.removeEntry(*remove)
+			if entries, _ := j.showEntries(timestamp); confirmRemoval(len(entries), *yes) {
+				e = j.removeEntry(timestamp)
+			}
 		}
 		if e != nil {
 			printError(e, 2)
+		} else if removed := len(j.Trash) - trashed; removed > 0 {
+			fmt.Println(brightGreen(fmt.Sprint(removed, " entries moved to the trash. Use --undo or --trash restore to get them back")))
+		} else {
+			fmt.Println("Nothing was removed")
+		}
+	} else if *trash != "" {
+		switch *trash {
+		case "list":
+			if len(j.Trash) == 0 {
+				printError(errors.New("the trash is empty"), 1)
+			} else {
+				printTrash(j.Trash, j.trashRetention(), *printJSON)
+			}
+		case "restore":
+			if restored, e := j.restoreEntries(flag.Args()); e != nil {
+				printError(e, 2)
+			} else {
+				fmt.Println(brightGreen(fmt.Sprint(len(restored), " entries restored")))
+			}
+		case "empty":
+			if len(j.Trash) == 0 {
+				printError(errors.New("the trash is empty"), 1)
+			} else if *yes || askChoice(fmt.Sprint("Permanently remove the ", len(j.Trash), " entries in the trash? [y]es/[n]o"), []string{"y", "n"}) == "y" {
+				fmt.Println(brightGreen(fmt.Sprint(j.emptyTrash(), " entries permanently removed")))
+			}
+		default:
+			printError(errors.New("wrong parameter with trash flag. Values: list, restore, empty"), 2)
+		}
+	} else if *retention != 0 {
+		if *retention < 0 {
+			printError(errors.New("the retention must be a positive number of days"), 2)
+		} else {
+			j.TrashRetention = *retention
+			fmt.Println(brightGreen(fmt.Sprint("Removed entries will be kept for ", *retention, " days")))
+		}
+	} else if *undo {
+		if operation, e := j.undo(); e != nil {
+			printError(e, 1)
+		} else {
+			fmt.Println(brightGreen("Undone: journal " + operation))
 		}
 	} else if *show != "" {
 		// get entry by date
@@ -134,8 +267,8 @@ func main() {
 				printEntries(entries, *printPlaintext, *printJSON)
 			}
 		} else {
-			// check if the parameter is some kind of date
-			entries, e := j.showEntries(*show)
+			// check if the parameter is some kind of date, maybe followed by a time
+			entries, e := j.showEntries(strings.Join(append([]string{*show}, flag.Args()...), " "))
 			if e != nil {
 				printError(e, 1)
 			} else {
@@ -147,11 +280,32 @@ func main() {
 		// concantenate all the keywords
 		keywords = append(keywords, *searchkeywords)
 		keywords = append(keywords, flag.Args()...)
-		entries, e := j.searchKeywords(keywords)
-		if e != nil {
-			printError(e, 1)
+		if *regex && *fuzzy {
+			printError(errors.New("--regex and --fuzzy can't be used together"), 2)
+		} else if *regex || *fuzzy {
+			var matcher textMatcher
+			var e error
+			if *regex {
+				// the expression might contain spaces
+				matcher, e = regexMatcher(strings.Join(keywords, " "))
+			} else {
+				matcher = fuzzyMatcher(keywords)
+			}
+
+			if e != nil {
+				printError(e, 2)
+			} else if entries, e := j.searchMatching(matcher); e != nil {
+				printError(e, 1)
+			} else {
+				printMatchingEntries(entries, matcher, *printPlaintext, *printJSON)
+			}
 		} else {
-			printEntries(entries, *printPlaintext, *printJSON)
+			results, e := j.searchKeywords(keywords)
+			if e != nil {
+				printError(e, 1)
+			} else {
+				printSearchResults(results, keywords, *printPlaintext, *printJSON)
+			}
 		}
 	} else if *searchtags != "" {
 		var tags []string
@@ -175,6 +329,165 @@ func main() {
 		} else {
 			printEntries(entries, *printPlaintext, *printJSON)
 		}
+	} else if *chart != "" {
+		entries, e := j.filterEntries(*query, *from, *to)
+		if e != nil {
+			printError(e, 2)
+		} else if points, e := chartPoints(entries, *chart, *by, *aggregate); e != nil {
+			printError(e, 1)
+		} else if e := printChart(points, *chart, *charttype); e != nil {
+			printError(e, 2)
+		}
+	} else if *onthisday {
+		day := time.Now()
+		if flag.NArg() > 0 {
+			var level int
+			day, level = parseDay(flag.Arg(0))
+			if level != 1 {
+				printError(errors.New("date was not provided correctly"), 2)
+				day = time.Time{}
+			}
+		}
+
+		if !day.IsZero() {
+			if groups, e := j.onThisDay(day); e != nil {
+				printError(e, 1)
+			} else {
+				printRetrospective(groups, *printPlaintext, *printJSON)
+			}
+		}
+	} else if *lookbacks != "" {
+		if *lookbacks == "none" {
+			j.Lookbacks = &[]string{}
+			fmt.Println(brightGreen("Lookbacks disabled"))
+		} else if clean, e := checkLookbacks(strings.Split(*lookbacks, ",")); e != nil {
+			printError(e, 2)
+		} else {
+			j.Lookbacks = &clean
+			if len(clean) == 0 {
+				j.Lookbacks = nil
+			}
+			fmt.Println(brightGreen("Lookbacks saved"))
+		}
+	} else if *dailyonthisday != "" {
+		switch *dailyonthisday {
+		case "on":
+			j.DailyOnThisDay = true
+			fmt.Println(brightGreen("Entries from the past will be shown once a day"))
+		case "off":
+			j.DailyOnThisDay = false
+			fmt.Println(brightGreen("Entries from the past won't be shown anymore"))
+		default:
+			printError(errors.New("wrong parameter with dailyonthisday flag. Values: on, off"), 2)
+		}
+	} else if *review != "" {
+		date := time.Now()
+		if flag.NArg() > 0 {
+			var level int
+			date, level = parseDay(flag.Arg(0))
+			if level == -1 {
+				printError(errors.New("date was not provided correctly"), 2)
+				date = time.Time{}
+			}
+		}
+
+		if !date.IsZero() {
+			entries, e := j.filterEntries(*query, "", "")
+			if e != nil {
+				printError(e, 2)
+			} else if r, e := computeReview(entries, *review, date, time.Now()); e != nil {
+				printError(e, 1)
+			} else {
+				printReview(r, *printMarkdown, *printJSON)
+			}
+		}
+	} else if *calendar != "" {
+		entries, e := j.filterEntries(*query, *from, *to)
+		if e != nil {
+			printError(e, 2)
+		} else if e := printCalendar(entries, *calendar, *field); e != nil {
+			printError(e, 2)
+		}
+	} else if *stats {
+		entries, e := j.filterEntries(strings.Join(append([]string{*query}, flag.Args()...), " "), *from, *to)
+		if e != nil {
+			printError(e, 2)
+		} else if stats, e := computeStats(entries, time.Now()); e != nil {
+			printError(e, 1)
+		} else {
+			printStats(stats)
+		}
+	} else if *export != "" {
+		entries, e := j.filterEntries(*query, *from, *to)
+		if e != nil {
+			printError(e, 2)
+		} else if written, unchanged, removed, e := exportEntries(entries, *export, *format, *by, *allday); e != nil {
+			printError(e, 2)
+		} else {
+			fmt.Println(brightGreen(fmt.Sprint(len(entries), " entries exported to ", *export, ": ", written, " files written, ", unchanged, " unchanged, ", removed, " removed")))
+		}
+	} else if *importfile != "" {
+		if imported, skipped, e := j.importFile(*importfile, *format, *columns); e != nil {
+			printError(e, 2)
+		} else {
+			fmt.Println(brightGreen(fmt.Sprint(imported, " entries imported, ", skipped, " already in the journal")))
+		}
+	} else if *merge != "" {
+		if other, e := j.openJournal(*merge, false); e != nil {
+			printError(e, 2)
+		} else if report, e := j.merge(other, *strategy, *dryrun); e != nil {
+			printError(e, 2)
+		} else {
+			printMergeReport(report, *dryrun)
+		}
+	} else if *history != "" {
+		if entry, revisions, e := j.entryHistory(*history); e != nil {
+			printError(e, 1)
+		} else {
+			printHistory(entry, revisions, *printJSON)
+		}
+	} else if *revert != "" {
+		if flag.NArg() != 1 {
+			printError(errors.New("provide the number of the revision. Usage: --revert id number"), 2)
+		} else if e := j.revertEntry(*revert, flag.Arg(0)); e != nil {
+			printError(e, 2)
+		} else {
+			fmt.Println(brightGreen("Revision " + flag.Arg(0) + " restored"))
+		}
+	} else if *move != "" || *copyto != "" {
+		target, action := *copyto, "copied"
+		if *move != "" {
+			target, action = *move, "moved"
+		}
+
+		if entries, e := j.selectEntries(strings.Join(flag.Args(), " "), *query, *from, *to); e != nil {
+			printError(e, 1)
+		} else if other, e := j.openJournal(target, true); e != nil {
+			printError(e, 2)
+		} else if transferred, skipped, e := j.transferEntries(entries, other, *move != ""); e != nil {
+			printError(e, 2)
+		} else {
+			otherChanged = true
+			fmt.Println(brightGreen(fmt.Sprint(transferred, " entries ", action, " to ", other.filename, ", ", skipped, " already there")))
+		}
+	} else if *publish != "" {
+		entries, e := j.filterEntries(*query, *from, *to)
+		if e != nil {
+			printError(e, 2)
+		} else if written, unchanged, e := j.publish(entries, *publish, *theme); e != nil {
+			printError(e, 2)
+		} else {
+			fmt.Println(brightGreen(fmt.Sprint("Website built in ", *publish, ": ", written, " files written, ", unchanged, " unchanged")))
+		}
+	} else if *query != "" {
+		// concantenate all the query parts
+		q := strings.Join(append([]string{*query}, flag.Args()...), " ")
+		entries, e := j.queryEntries(q, *from, *to)
+		if e != nil {
+			printError(e, 1)
+		} else {
+			printEntries(entries, *printPlaintext, *printJSON)
+		}
 	} else if *tags {
 		var tags map[string]int
 		tags, e := j.getAllTags()
@@ -183,14 +496,115 @@ func main() {
 		} else {
 			printTags(tags)
 		}
+	} else if *renametag != "" {
+		if flag.NArg() != 1 {
+			printError(errors.New("provide the new name of the tag. Usage: --renametag old new"), 2)
+		} else if e := j.checkRenameTag(*renametag, flag.Arg(0)); e != nil {
+			// the dry run fails like the real operation
+			printError(e, 1)
+		} else if printTagPreview(j.entriesWithTag(*renametag), *dryrun) {
+			if changed, e := j.renameTag(*renametag, flag.Arg(0)); e != nil {
+				printError(e, 1)
+			} else {
+				fmt.Println(brightGreen(fmt.Sprint("Tag renamed in ", changed, " entries")))
+			}
+		}
+	} else if *mergetags != "" {
+		if flag.NArg() != 1 {
+			printError(errors.New("provide the tag to merge into. Usage: --mergetags from to"), 2)
+		} else if e := j.checkMergeTags(*mergetags, flag.Arg(0)); e != nil {
+			printError(e, 1)
+		} else if printTagPreview(j.entriesWithTag(*mergetags), *dryrun) {
+			if changed, e := j.mergeTags(*mergetags, flag.Arg(0)); e != nil {
+				printError(e, 1)
+			} else {
+				fmt.Println(brightGreen(fmt.Sprint("Tags merged in ", changed, " entries")))
+			}
+		}
+	} else if *deletetag != "" {
+		if e := j.checkDeleteTag(*deletetag); e != nil {
+			printError(e, 1)
+		} else if printTagPreview(j.entriesWithTag(*deletetag), *dryrun) {
+			if changed, e := j.deleteTag(*deletetag); e != nil {
+				printError(e, 1)
+			} else {
+				fmt.Println(brightGreen(fmt.Sprint("Tag removed from ", changed, " entries")))
+			}
+		}
+	} else if *aliastag != "" {
+		if flag.NArg() != 1 {
+			printError(errors.New("provide the tag replacing the alias. Usage: --aliastag alias tag"), 2)
+		} else if e := j.checkTagAlias(*aliastag, flag.Arg(0)); e != nil {
+			printError(e, 1)
+		} else {
+			// the alias only applies to new entries
+			if existing := j.entriesWithTag(*aliastag); len(existing) > 0 {
+				fmt.Println(brightYellow(fmt.Sprint(len(existing), " existing entries use this tag. Use --mergetags to change them")))
+				printEntries(existing, true, false)
+			}
+			if *dryrun {
+				fmt.Println(brightYellow("Dry run, nothing has been changed"))
+			} else if e := j.setTagAlias(*aliastag, flag.Arg(0)); e != nil {
+				printError(e, 1)
+			} else {
+				fmt.Println(brightGreen("Alias saved"))
+			}
+		}
+	} else if *removealias != "" {
+		if e := j.removeTagAlias(*removealias); e != nil {
+			printError(e, 1)
+		} else {
+			fmt.Println(brightGreen("Alias removed"))
+		}
+	} else if *aliases {
+		if len(j.TagAliases) == 0 {
+			printError(errors.New("no aliases found"), 1)
+		} else {
+			printTagAliases(j.TagAliases)
+		}
 	} else if *fields {
-		var fields []map[string]string
-		fields, e := j.getAllFields()
+		entries, e := j.entriesInRange(*from, *to)
 		if e != nil {
+			printError(e, 2)
+		} else if fields, e := j.getAllFields(entries); e != nil {
 			printError(e, 1)
 		} else {
 			printFields(fields)
 		}
+	} else if *field != "" {
+		entries, e := j.entriesInRange(*from, *to)
+		if e != nil {
+			printError(e, 2)
+		} else if periods, e := aggregateField(entries, *field, *by); e != nil {
+			printError(e, 1)
+		} else {
+			printFieldPeriods(strings.TrimPrefix(*field, "@"), periods)
+		}
+	} else if *setschema != "" {
+		if *setschema == "none" {
+			j.FieldSchema = nil
+			fmt.Println(brightGreen("Field schema removed"))
+		} else if newSchema, e := loadFieldSchema(*setschema); e != nil {
+			printError(e, 2)
+		} else {
+			j.FieldSchema = newSchema
+			fmt.Println(brightGreen("Field schema saved"))
+		}
+	} else if *schema {
+		if len(j.FieldSchema) == 0 {
+			printError(errors.New("the journal has no field schema"), 1)
+		} else {
+			printFieldSchema(j.FieldSchema)
+		}
+	} else if *lintfields {
+		violations, e := j.lintFields()
+		if e != nil {
+			printError(e, 1)
+		} else if len(violations) == 0 {
+			fmt.Println(brightGreen("All fields follow the schema"))
+		} else {
+			printFieldViolations(violations)
+		}
 	} else if !(*encrypt || *decrypt || *removePassword) {
 		// not a single valid option has been called
 		flag.PrintDefaults()
@@ -198,6 +612,22 @@ func main() {
 		return
 	}
 
+	// keep what the command changed, to update the search index and to undo
+	// it. An undo brings back the history too, so it's not a change to record.
+	// Undoing only this journal when another one changed would duplicate or
+	// lose entries
+	if changes, changed := j.changesSince(before, strings.Join(os.Args[1:], " ")); changed {
+		j.updateIndex(changes)
+		if !*undo {
+			j.recordRevisions(changes)
+			changes.keepHistory(before)
+			j.Undo = &changes
+		}
+	}
+	if otherChanged {
+		j.Undo = nil
+	}
+
 	if *encrypt {
 		var password string
 		password, e = getPassword("New password:")
@@ -207,11 +637,11 @@ func main() {
 		} else {
 			j.SetPassword(password)
 			j.encrypt()
-			fmt.Println(colorize.BrightGreen("Database encrypted"))
+			fmt.Println(brightGreen("Database encrypted"))
 		}
 	} else if *removePassword {
 		e = j.save()
-		fmt.Println(colorize.BrightGreen("Database permanently decrypted"))
+		fmt.Println(brightGreen("Database permanently decrypted"))
 	} else if *decrypt {
 		j.encrypt()
 	} else {
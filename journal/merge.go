@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// strategies to solve the conflicts between two versions of an entry
+var mergeStrategies = []string{"both", "newer", "interactive"}
+
+// entry with the same ID in both journals, but different content
+type mergeConflict struct {
+	current, other Entry
+	// how the conflict was solved: current, other or both
+	resolution string
+}
+
+// result of a merge
+type mergeReport struct {
+	added      []Entry
+	duplicates int
+	conflicts  []mergeConflict
+}
+
+// returns the hash of the content of an entry: timestamp
+// (to the minute), title, content, tags and fields
+func entryHash(entry Entry) string {
+	tags := append([]string{}, entry.Tags...)
+	sort.Strings(tags)
+	var fields []string
+	for _, k := range sortedFieldKeys(entry.Fields) {
+		fields = append(fields, k+"="+entry.Fields[k])
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(strings.Join([]string{
+		entry.timeObj.Format("2006-01-02 15:04"),
+		entry.Title,
+		entry.Content,
+		strings.Join(tags, " "),
+		strings.Join(fields, " "),
+	}, "\x00")))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// returns when an entry was last changed, formatted as its timestamp
+func lastChange(entry Entry) string {
+	if entry.Updated != "" {
+		return entry.Updated
+	}
+	return entry.Timestamp
+}
+
+// asks which version of a conflicting entry to keep
+func askConflict(c mergeConflict) string {
+	printError(errors.New("conflict: the entry was changed in both journals"), 1)
+	printColoredEntry(c.current, nil)
+	printColoredEntry(c.other, nil)
+	switch askChoice("\nKeep the [c]urrent version, the [o]ther one or [b]oth?", []string{"c", "o", "b"}) {
+	case "c":
+		return "current"
+	case "o":
+		return "other"
+	}
+	return "both"
+}
+
+// merge the entries of another journal into this one.
+// Entries already in the journal (same ID or same content) are skipped.
+// Entries with the same ID but different content are conflicts, solved
+// with a strategy: both (keep both versions), newer (keep the version changed
+// last, or the one of the journal changed last) or interactive (ask for each conflict)
+func (j *Journal) merge(other *Journal, strategy string, dryRun bool) (report mergeReport, e error) {
+	valid := false
+	for _, s := range mergeStrategies {
+		valid = valid || s == strategy
+	}
+	if !valid {
+		return report, errors.New("unknown merge strategy '" + strategy + "'. Strategies: " + strings.Join(mergeStrategies, ", "))
+	}
+
+	hashes := make(map[string]bool)
+	for _, entry := range j.Entries {
+		hashes[entryHash(entry)] = true
+	}
+	// the time of the last change of the files. Copying a journal without
+	// keeping its modification time makes it look newer
+	otherIsNewer := other.modTime().After(j.modTime())
+
+	for _, entry := range other.Entries {
+		if hashes[entryHash(entry)] {
+			report.duplicates++
+			continue
+		}
+		hashes[entryHash(entry)] = true
+
+		position, found := j.findEntryByID(entry.ID)
+		if !found {
+			report.added = append(report.added, entry)
+			continue
+		}
+
+		c := mergeConflict{current: j.Entries[position], other: entry, resolution: "both"}
+		switch strategy {
+		case "newer":
+			// entries know when they were last changed, or when they were written
+			currentTime, otherTime := lastChange(c.current), lastChange(entry)
+			otherNewer := otherTime > currentTime
+			if otherTime == currentTime {
+				otherNewer = otherIsNewer
+			}
+			c.resolution = "current"
+			if otherNewer {
+				c.resolution = "other"
+			}
+		case "interactive":
+			c.resolution = ""
+			if !dryRun {
+				c.resolution = askConflict(c)
+			}
+		}
+		report.conflicts = append(report.conflicts, c)
+	}
+
+	if dryRun {
+		return report, nil
+	}
+
+	// apply the changes
+	for _, entry := range report.added {
+		j.Entries = append(j.Entries, entry)
+	}
+	for _, c := range report.conflicts {
+		switch c.resolution {
+		case "other":
+			position, _ := j.findEntryByID(c.current.ID)
+			j.Entries[position] = c.other
+		case "both":
+			entry := c.other
+			entry.ID = j.newEntryID()
+			j.Entries = append(j.Entries, entry)
+		}
+	}
+	sort.Slice(j.Entries, func(i, k int) bool { return j.Entries[i].timeObj.Before(j.Entries[k].timeObj) })
+
+	return report, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lookbacks used if the journal doesn't set any
+var defaultLookbacks = []string{"1w", "1m"}
+
+// entries written on a day in the past
+type retrospectiveGroup struct {
+	label   string
+	date    time.Time
+	entries []Entry
+}
+
+// parses a lookback in format Nd, Nw, Nm or Ny (e.g. 2w -> two weeks ago)
+// the words day, week, month and year are the same as 1d, 1w, 1m and 1y
+func parseLookback(lookback string) (years, months, days int, label string, e error) {
+	lookback = strings.ToLower(strings.TrimSpace(lookback))
+	switch lookback {
+	case "day", "week", "month", "year":
+		lookback = "1" + lookback[:1]
+	}
+
+	if len(lookback) < 2 {
+		return 0, 0, 0, "", errors.New("cannot parse lookback '" + lookback + "'. Format: 1d, 2w, 6m, 1y")
+	}
+	amount, e := strconv.Atoi(lookback[:len(lookback)-1])
+	if e != nil || amount <= 0 {
+		return 0, 0, 0, "", errors.New("cannot parse lookback '" + lookback + "'. Format: 1d, 2w, 6m, 1y")
+	}
+
+	var unit string
+	switch lookback[len(lookback)-1] {
+	case 'd':
+		days, unit = amount, "day"
+	case 'w':
+		days, unit = amount*7, "week"
+	case 'm':
+		months, unit = amount, "month"
+	case 'y':
+		years, unit = amount, "year"
+	default:
+		return 0, 0, 0, "", errors.New("cannot parse lookback '" + lookback + "'. Format: 1d, 2w, 6m, 1y")
+	}
+
+	if amount > 1 {
+		unit += "s"
+	}
+	return years, months, days, strconv.Itoa(amount) + " " + unit + " ago", nil
+}
+
+// check the lookbacks, returning them cleaned
+func checkLookbacks(lookbacks []string) (clean []string, e error) {
+	for _, l := range lookbacks {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		if _, _, _, _, e := parseLookback(l); e != nil {
+			return nil, e
+		}
+		clean = append(clean, strings.ToLower(strings.TrimSpace(l)))
+	}
+	return clean, nil
+}
+
+// returns the lookbacks of the journal
+func (j *Journal) getLookbacks() []string {
+	if j.Lookbacks == nil {
+		return defaultLookbacks
+	}
+	return *j.Lookbacks
+}
+
+// get the entries written on the same calendar day in the previous years
+// and on the days set by the lookbacks
+func (j *Journal) onThisDay(day time.Time) (groups []retrospectiveGroup, e error) {
+	// group the entries by year, most recent first
+	byYear := make(map[int][]Entry)
+	for _, entry := range j.Entries {
+		if entry.timeObj.Year() < day.Year() && sameDayOfYear(entry.timeObj, day) {
+			byYear[entry.timeObj.Year()] = append(byYear[entry.timeObj.Year()], entry)
+		}
+	}
+
+	var years []int
+	for y := range byYear {
+		years = append(years, y)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(years)))
+
+	for _, y := range years {
+		ago := day.Year() - y
+		label := fmt.Sprint(ago, " years ago")
+		if ago == 1 {
+			label = "1 year ago"
+		}
+		date := time.Date(y, day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+		groups = append(groups, retrospectiveGroup{label: label, date: date, entries: byYear[y]})
+	}
+
+	for _, l := range j.getLookbacks() {
+		years, months, days, label, e := parseLookback(l)
+		if e != nil {
+			return nil, e
+		}
+
+		date := day.AddDate(-years, -months, -days)
+		var entries []Entry
+		for _, entry := range j.Entries {
+			if sameDay(entry.timeObj, date) {
+				entries = append(entries, entry)
+			}
+		}
+		if len(entries) > 0 {
+			groups = append(groups, retrospectiveGroup{label: label, date: date, entries: entries})
+		}
+	}
+
+	if len(groups) == 0 {
+		return nil, errors.New("no entries found on this day in the past")
+	}
+	return groups, nil
+}
+
+// check if the entries from the past have already been shown today
+func (j *Journal) onThisDayShownToday() bool {
+	return j.LastOnThisDay == time.Now().Format("2006-01-02")
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// properties written by the exporter, not containing fields
+const (
+	orgIDProperty      = "ID"
+	orgCreatedProperty = "CREATED"
+	// exact tags, if they can't be written as org tags
+	orgTagsProperty = "JOURNAL_TAGS"
+	// exact title, if the heading would be read back differently
+	orgTitleProperty = "JOURNAL_TITLE"
+	// prefix of the properties containing fields, so that they can't
+	// be confused with the properties above
+	orgFieldPrefix = "@"
+)
+
+// layout of the org timestamps (e.g. [2021-03-01 Mon 10:00])
+const orgTimestampLayout = "[2006-01-02 Mon 15:04]"
+
+var (
+	// headings of a date tree: years, months and days
+	orgYearHeading  = regexp.MustCompile(`^(\d{4})$`)
+	orgMonthHeading = regexp.MustCompile(`^(\d{4}-\d{2})( \w+)?$`)
+	orgDayHeading   = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})( \w+)?$`)
+	// tags at the end of a heading (e.g. Title :work:fun:)
+	orgHeadingTags = regexp.MustCompile(`\s+(:[\p{L}\p{N}_@#%]+)+:$`)
+	// property in a drawer (e.g. :run: 5km)
+	orgProperty = regexp.MustCompile(`^:([^:\s]+):\s*(.*)$`)
+	// timestamp in a heading or in the content
+	orgTimestamp = regexp.MustCompile(`[\[<](\d{4}-\d{2}-\d{2})(?: \w+)?(?: (\d{1,2}:\d{2}))?[\]>]`)
+)
+
+// returns a tag as an org tag, which can only contain letters, numbers, _, @, # and %
+func orgTag(tag string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_@#%", r) {
+			return r
+		}
+		return '_'
+	}, tag)
+}
+
+// escape of the characters that can't be part of an org property name
+var (
+	orgPropertyEscaper   = strings.NewReplacer("%", "%25", ":", "%3A", " ", "%20", "\t", "%09")
+	orgPropertyUnescaper = strings.NewReplacer("%25", "%", "%3A", ":", "%20", " ", "%09", "\t")
+)
+
+// returns a field key as an org property name (e.g. @run)
+func orgFieldProperty(key string) string {
+	return orgFieldPrefix + orgPropertyEscaper.Replace(key)
+}
+
+// returns the field key of an org property name, if it contains a field
+func orgFieldKey(name string) (key string, ok bool) {
+	if !strings.HasPrefix(name, orgFieldPrefix) {
+		return "", false
+	}
+	return orgPropertyUnescaper.Replace(strings.TrimPrefix(name, orgFieldPrefix)), true
+}
+
+// returns true if a heading is part of the date tree
+func orgDateHeading(heading string) bool {
+	return orgYearHeading.MatchString(heading) || orgMonthHeading.MatchString(heading) || orgDayHeading.MatchString(heading)
+}
+
+// returns the title, the tags and the timestamp of an entry heading
+func parseOrgHeading(heading string) (title string, tags []string, timeObj time.Time, hasDate bool) {
+	// tags at the end of the heading
+	if t := orgHeadingTags.FindString(heading); t != "" {
+		heading = strings.TrimSuffix(heading, t)
+		tags = strings.Split(strings.Trim(strings.TrimSpace(t), ":"), ":")
+	}
+	// timestamp in the heading
+	if timeObj, hasDate = parseOrgTimestamp(heading); hasDate {
+		heading = strings.TrimSpace(orgTimestamp.ReplaceAllString(heading, ""))
+	}
+	return heading, tags, timeObj, hasDate
+}
+
+// returns the entries as an org-mode date tree (year, month, day),
+// with the tags as org tags and the fields in the properties drawer
+func orgEntries(entries []Entry) []byte {
+	var b strings.Builder
+	var year, month, day string
+
+	for _, entry := range sortedEntries(entries) {
+		// date tree headings
+		if y := entry.timeObj.Format("2006"); y != year {
+			year, month, day = y, "", ""
+			b.WriteString("* " + year + "\n")
+		}
+		if m := entry.timeObj.Format("2006-01 January"); m != month {
+			month, day = m, ""
+			b.WriteString("** " + month + "\n")
+		}
+		if d := entry.timeObj.Format("2006-01-02 Monday"); d != day {
+			day = d
+			b.WriteString("*** " + day + "\n")
+		}
+
+		// entry heading
+		b.WriteString("**** " + entry.Title)
+		tagsChanged := false
+		if len(entry.Tags) > 0 {
+			var tags []string
+			for _, t := range entry.Tags {
+				tags = append(tags, orgTag(t))
+				tagsChanged = tagsChanged || orgTag(t) != t
+			}
+			b.WriteString(" :" + strings.Join(tags, ":") + ":")
+		}
+		b.WriteString("\n")
+
+		// properties
+		b.WriteString(":PROPERTIES:\n")
+		b.WriteString(":" + orgIDProperty + ": " + entry.ID + "\n")
+		b.WriteString(":" + orgCreatedProperty + ": " + entry.timeObj.Format(orgTimestampLayout) + "\n")
+		// titles that look like a date, a timestamp or tags. The tags
+		// read from the heading might be wrong too, so they are kept
+		if title, _, _, _ := parseOrgHeading(strings.TrimSpace(entry.Title)); title != entry.Title || orgDateHeading(entry.Title) {
+			b.WriteString(":" + orgTitleProperty + ": " + entry.Title + "\n")
+			tagsChanged = true
+		}
+		if tagsChanged {
+			b.WriteString(":" + orgTagsProperty + ": " + strings.Join(entry.Tags, " ") + "\n")
+		}
+		for _, k := range sortedFieldKeys(entry.Fields) {
+			b.WriteString(":" + orgFieldProperty(k) + ": " + entry.Fields[k] + "\n")
+		}
+		b.WriteString(":END:\n")
+
+		// content. Lines that look like headings are escaped with a comma
+		if entry.Content != "" {
+			for _, line := range strings.Split(entry.Content, "\n") {
+				if strings.HasPrefix(line, "*") || strings.HasPrefix(line, ",*") {
+					line = "," + line
+				}
+				b.WriteString(line + "\n")
+			}
+		}
+	}
+
+	return []byte(b.String())
+}
+
+// parses an org timestamp (date and optional time)
+func parseOrgTimestamp(text string) (timeObj time.Time, ok bool) {
+	match := orgTimestamp.FindStringSubmatch(text)
+	if match == nil {
+		return time.Time{}, false
+	}
+	value := match[1]
+	if match[2] != "" {
+		value += " " + match[2]
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02 3:04", "2006-01-02"} {
+		if timeObj, e := time.Parse(layout, value); e == nil {
+			return timeObj, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// reads the entries of an org file. Each heading that is not part of the date
+// tree is an entry: date tree headings have no properties drawer, so a heading
+// followed by one is always an entry. The date is read from the CREATED property,
+// from a timestamp in the heading or from the day heading containing the entry
+func parseOrgEntries(data []byte) (entries []Entry, e error) {
+	var entry *Entry
+	var content []string
+	var dayDate time.Time
+	hasDate, inDrawer := false, false
+	// line of the heading of the current entry
+	headingLine := -1
+
+	// add the current entry to the list
+	finish := func() error {
+		if entry == nil {
+			return nil
+		}
+		if !hasDate {
+			if dayDate.IsZero() {
+				return errors.New("cannot find the date of entry '" + entry.Title + "'")
+			}
+			entry.timeObj = dayDate
+		}
+		entry.Content = strings.TrimSpace(strings.Join(content, "\n"))
+		entries = append(entries, *entry)
+		entry = nil
+		return nil
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	for number, line := range lines {
+		// headings
+		if stars := len(line) - len(strings.TrimLeft(line, "*")); stars > 0 && len(line) > stars && line[stars] == ' ' {
+			if e := finish(); e != nil {
+				return nil, e
+			}
+			heading := strings.TrimSpace(line[stars:])
+
+			drawer := number+1 < len(lines) && strings.EqualFold(strings.TrimSpace(lines[number+1]), ":PROPERTIES:")
+			if match := orgDayHeading.FindStringSubmatch(heading); match != nil && !drawer {
+				dayDate, _ = time.Parse("2006-01-02", match[1])
+				continue
+			}
+			if orgDateHeading(heading) && !drawer {
+				dayDate = time.Time{}
+				continue
+			}
+
+			entry = &Entry{Fields: make(map[string]string)}
+			content = nil
+			inDrawer, headingLine = false, number
+			entry.Title, entry.Tags, entry.timeObj, hasDate = parseOrgHeading(heading)
+			continue
+		}
+
+		if entry == nil {
+			continue
+		}
+
+		// properties drawer, only right after the heading
+		trimmed := strings.TrimSpace(line)
+		if strings.EqualFold(trimmed, ":PROPERTIES:") && number == headingLine+1 {
+			inDrawer = true
+			continue
+		}
+		if inDrawer {
+			if strings.EqualFold(trimmed, ":END:") {
+				inDrawer = false
+				continue
+			}
+			match := orgProperty.FindStringSubmatch(trimmed)
+			if match == nil {
+				return nil, errors.New("line " + strconv.Itoa(number+1) + ": cannot parse property '" + trimmed + "'")
+			}
+
+			switch match[1] {
+			case orgIDProperty:
+				entry.ID = match[2]
+			case orgCreatedProperty:
+				if timeObj, ok := parseOrgTimestamp(match[2]); ok {
+					entry.timeObj, hasDate = timeObj, true
+				}
+			case orgTagsProperty:
+				entry.Tags = splitTags(match[2])
+			case orgTitleProperty:
+				entry.Title = match[2]
+			default:
+				// properties written by other tools are kept as fields too
+				key, ok := orgFieldKey(match[1])
+				if !ok {
+					key = match[1]
+				}
+				entry.Fields[key] = match[2]
+			}
+			continue
+		}
+
+		// content, removing the escape of lines that look like headings
+		if strings.HasPrefix(line, ",*") || strings.HasPrefix(line, ",,*") {
+			line = line[1:]
+		}
+		content = append(content, line)
+	}
+
+	if e := finish(); e != nil {
+		return nil, e
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("no entries found in file")
+	}
+	return entries, nil
+}
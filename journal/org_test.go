@@ -0,0 +1,89 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOrgRoundTrip(t *testing.T) {
+	timeObj := time.Date(2021, 3, 1, 10, 30, 0, 0, time.UTC)
+	entries := []Entry{
+		{ID: "a", Title: "Plain title", Content: "Some text", Tags: []string{"work", "fun"}, Fields: map[string]string{"run": "5km"}},
+		{ID: "b", Title: "2021", Tags: []string{"work/meeting"}, Fields: map[string]string{}},
+		{ID: "c", Title: "2021-03-01 Monday", Fields: map[string]string{}},
+		{ID: "d", Title: "2021-03 March", Fields: map[string]string{}},
+		{ID: "e", Title: "Meeting [2021-01-01 Fri]", Fields: map[string]string{}},
+		{ID: "f", Title: "Ends like tags :x:y:", Fields: map[string]string{}},
+		{ID: "g", Title: "Reserved fields", Fields: map[string]string{"ID": "1", "CREATED": "2", "JOURNAL_TAGS": "3", "JOURNAL_TITLE": "4"}},
+		{ID: "h", Title: "Escaped fields", Fields: map[string]string{"a:b": "1", "two words": "2", "50%": "3", "@at": "4"}},
+		{ID: "i", Title: "Headings in the content", Content: "* not a heading\n,* escaped\n:PROPERTIES:\n:x: y\n:END:", Fields: map[string]string{}},
+	}
+	for i := range entries {
+		entries[i].timeObj = timeObj.Add(time.Duration(i) * time.Minute)
+	}
+
+	parsed, e := parseOrgEntries(orgEntries(entries))
+	if e != nil {
+		t.Fatalf("parseOrgEntries: unexpected error %v", e)
+	}
+	if len(parsed) != len(entries) {
+		t.Fatalf("parseOrgEntries returned %d entries, want %d", len(parsed), len(entries))
+	}
+	for i, entry := range entries {
+		got := parsed[i]
+		if got.ID != entry.ID || got.Title != entry.Title || got.Content != entry.Content ||
+			!got.timeObj.Equal(entry.timeObj) || !reflect.DeepEqual(got.Tags, entry.Tags) ||
+			!reflect.DeepEqual(got.Fields, entry.Fields) {
+			t.Errorf("entry %d = %+v, want %+v", i, got, entry)
+		}
+	}
+}
+
+func TestParseOrgEntries(t *testing.T) {
+	data := []byte(`* 2021
+** 2021-03 March
+*** 2021-03-01 Monday
+**** Dated by the day heading :work:
+Some text
+**** Dated by the heading [2021-03-05 Fri 18:00]
+:PROPERTIES:
+:mood: good
+:@run: 5
+:END:
+**** Text starting like a drawer
+
+:PROPERTIES:
+`)
+
+	want := []struct {
+		title, date, content string
+		tags                 []string
+		fields               map[string]string
+	}{
+		{"Dated by the day heading", "2021-03-01 00:00", "Some text", []string{"work"}, map[string]string{}},
+		{"Dated by the heading", "2021-03-05 18:00", "", nil, map[string]string{"mood": "good", "run": "5"}},
+		{"Text starting like a drawer", "2021-03-01 00:00", ":PROPERTIES:", nil, map[string]string{}},
+	}
+
+	parsed, e := parseOrgEntries(data)
+	if e != nil {
+		t.Fatalf("parseOrgEntries: unexpected error %v", e)
+	}
+	if len(parsed) != len(want) {
+		t.Fatalf("parseOrgEntries returned %d entries, want %d", len(parsed), len(want))
+	}
+	for i, w := range want {
+		got := parsed[i]
+		if got.Title != w.title || got.timeObj.Format("2006-01-02 15:04") != w.date || got.Content != w.content ||
+			!reflect.DeepEqual(got.Tags, w.tags) || !reflect.DeepEqual(got.Fields, w.fields) {
+			t.Errorf("entry %d = %+v, want %+v", i, got, w)
+		}
+	}
+
+	for _, data := range []string{"", "* 2021\n", "* Undated entry\n"} {
+		if _, e := parseOrgEntries([]byte(data)); e == nil {
+			t.Errorf("parseOrgEntries(%q): expected an error", data)
+		}
+	}
+}
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// default theme of the published sites
+//
+//go:embed theme
+var embeddedTheme embed.FS
+
+// entries with these tags (or their descendants) are never published
+var unpublishedTags = []string{"private", "sealed"}
+
+// pages of a theme, each one rendered inside layout.html
+var themePages = []string{"index.html", "month.html", "tag.html", "field.html", "search.html", "calendar.html"}
+
+// files of a theme copied as they are
+var themeAssets = []string{"style.css", "search.js"}
+
+// link to a page of the site
+type siteLink struct {
+	Name  string
+	URL   string
+	Count int
+}
+
+// field of an entry, linking to the page of the field
+type siteField struct {
+	Key, Value, URL string
+}
+
+// entry as shown in the site
+type siteEntry struct {
+	ID, Title, Date, Time, Anchor, URL string
+	Paragraphs                         []string
+	Tags                               []siteLink
+	Fields                             []siteField
+}
+
+// month in the index of the site
+type siteYear struct {
+	Year   string
+	Count  int
+	Months []siteLink
+}
+
+// value of a field in an entry, for the field pages
+type siteFieldValue struct {
+	Entry siteEntry
+	Value string
+}
+
+// statistics of a field, for the field pages
+type siteFieldSummary struct {
+	Entries, Distinct   int
+	Numeric             bool
+	Min, Max, Sum, Mean string
+}
+
+// day of the calendar page
+type siteDay struct {
+	Date, URL string
+	Count     int
+	Level     int
+}
+
+// year of the calendar page, as weeks of 7 days (empty days have no date)
+type siteCalendar struct {
+	Year  string
+	Weeks [][]siteDay
+}
+
+// entry in the search index
+type searchDocument struct {
+	Title string   `json:"title"`
+	Date  string   `json:"date"`
+	URL   string   `json:"url"`
+	Tags  []string `json:"tags"`
+	Words []string `json:"words"`
+}
+
+// data passed to each page of the site
+type sitePage struct {
+	// site name
+	Site string
+	// page title
+	Title string
+	// relative path to the root of the site (e.g. ../../)
+	Root string
+	// content of the page, depending on the template
+	Years     []siteYear
+	Entries   []siteEntry
+	Tags      []siteLink
+	Fields    []siteLink
+	Values    []siteFieldValue
+	Summary   *siteFieldSummary
+	Calendars []siteCalendar
+	Previous  *siteLink
+	Next      *siteLink
+}
+
+// returns a name that can be safely used in a path
+func slug(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' {
+			return unicode.ToLower(r)
+		}
+		return '-'
+	}, name)
+}
+
+// returns a path for each name, adding a number to the ones whose path
+// is already used by another name (e.g. "a b" and "a-b")
+func uniquePagePaths(names []string, pagePath func(string) string) map[string]string {
+	paths := make(map[string]string)
+	used := make(map[string]bool)
+	for _, name := range names {
+		path := pagePath(name)
+		base := strings.TrimSuffix(path, ".html")
+		for n := 2; used[path]; n++ {
+			path = base + "-" + strconv.Itoa(n) + ".html"
+		}
+		used[path] = true
+		paths[name] = path
+	}
+	return paths
+}
+
+// returns the path of the page of a tag. Tag levels become folders
+func tagPagePath(tag string) string {
+	var levels []string
+	for _, l := range strings.Split(tag, tagSeparator) {
+		levels = append(levels, slug(l))
+	}
+	return "tags/" + strings.Join(levels, "/") + ".html"
+}
+
+// returns the path of the page of a field
+func fieldPagePath(key string) string {
+	return "fields/" + slug(key) + ".html"
+}
+
+// returns the path of the page of a month
+func monthPagePath(date time.Time) string {
+	return date.Format("2006/01") + ".html"
+}
+
+// returns the relative path from a page to the root of the site
+func rootOf(path string) string {
+	return strings.Repeat("../", strings.Count(path, "/"))
+}
+
+// returns the entries that can be published, excluding the private
+// and the sealed ones
+func publicEntries(entries []Entry) (public []Entry) {
+	for _, entry := range entries {
+		if !entryHasAnyTag(entry, unpublishedTags) {
+			public = append(public, entry)
+		}
+	}
+	return public
+}
+
+// returns an entry as shown in a page, with the links relative to root
+func (r *siteRenderer) newSiteEntry(entry Entry, root string) (s siteEntry) {
+	s.ID = entry.ID
+	s.Title = entry.Title
+	s.Date = entry.timeObj.Format("Monday 2006-01-02")
+	s.Time = entry.timeObj.Format("15:04")
+	s.Anchor = "entry-" + entry.ID
+	s.URL = root + monthPagePath(entry.timeObj) + "#" + s.Anchor
+
+	for _, p := range strings.Split(entry.Content, "\n") {
+		if strings.TrimSpace(p) != "" {
+			s.Paragraphs = append(s.Paragraphs, p)
+		}
+	}
+	for _, t := range entry.Tags {
+		s.Tags = append(s.Tags, siteLink{Name: t, URL: root + r.tagPaths[t]})
+	}
+	for _, k := range sortedFieldKeys(entry.Fields) {
+		s.Fields = append(s.Fields, siteField{Key: k, Value: entry.Fields[k], URL: root + r.fieldPaths[k]})
+	}
+	return s
+}
+
+// returns the entries as shown in a page
+func (r *siteRenderer) newSiteEntries(entries []Entry, root string) (s []siteEntry) {
+	for _, entry := range entries {
+		s = append(s, r.newSiteEntry(entry, root))
+	}
+	return s
+}
+
+// renders the templates of a theme
+type siteRenderer struct {
+	templates map[string]*template.Template
+	files     []exportFile
+	site      string
+	// paths of the pages of the tags and of the fields, without collisions
+	tagPaths, fieldPaths map[string]string
+}
+
+// loads the pages of a theme
+func newSiteRenderer(theme fs.FS, site string) (r *siteRenderer, e error) {
+	r = &siteRenderer{templates: make(map[string]*template.Template), site: site}
+	for _, page := range themePages {
+		t, e := template.ParseFS(theme, "layout.html", page)
+		if e != nil {
+			return nil, errors.New("cannot load theme page " + page + ": " + e.Error())
+		}
+		r.templates[page] = t
+	}
+
+	for _, asset := range themeAssets {
+		content, e := fs.ReadFile(theme, asset)
+		if e != nil {
+			return nil, errors.New("cannot load theme file " + asset)
+		}
+		r.files = append(r.files, exportFile{path: asset, content: content})
+	}
+	return r, nil
+}
+
+// renders a page of the site in path
+func (r *siteRenderer) render(page, path string, data sitePage) (e error) {
+	data.Site = r.site
+	data.Root = rootOf(path)
+
+	var b bytes.Buffer
+	if e := r.templates[page].ExecuteTemplate(&b, "layout.html", data); e != nil {
+		return errors.New("cannot render " + path + ": " + e.Error())
+	}
+	r.files = append(r.files, exportFile{path: path, content: b.Bytes()})
+	return nil
+}
+
+// returns the pages of the months, with links to the previous and next ones
+func (r *siteRenderer) renderMonths(entries []Entry) (years []siteYear, e error) {
+	var months []time.Time
+	byMonth := make(map[time.Time][]Entry)
+	for _, entry := range entries {
+		month, _ := periodStart(entry.timeObj, "month")
+		if _, ok := byMonth[month]; !ok {
+			months = append(months, month)
+		}
+		byMonth[month] = append(byMonth[month], entry)
+	}
+
+	for i, month := range months {
+		path := monthPagePath(month)
+		page := sitePage{Title: month.Format("January 2006"), Entries: r.newSiteEntries(byMonth[month], rootOf(path))}
+		if i > 0 {
+			page.Previous = &siteLink{Name: months[i-1].Format("January 2006"), URL: rootOf(path) + monthPagePath(months[i-1])}
+		}
+		if i < len(months)-1 {
+			page.Next = &siteLink{Name: months[i+1].Format("January 2006"), URL: rootOf(path) + monthPagePath(months[i+1])}
+		}
+		if e := r.render("month.html", path, page); e != nil {
+			return nil, e
+		}
+
+		// add the month to the index, most recent year first
+		link := siteLink{Name: month.Format("January"), URL: path, Count: len(byMonth[month])}
+		if len(years) == 0 || years[0].Year != month.Format("2006") {
+			years = append([]siteYear{{Year: month.Format("2006")}}, years...)
+		}
+		years[0].Count += link.Count
+		years[0].Months = append(years[0].Months, link)
+	}
+	return years, nil
+}
+
+// returns the tags of the entries, including the parents of hierarchical tags
+func siteTags(entries []Entry) (names []string) {
+	for _, entry := range entries {
+		for _, t := range entry.Tags {
+			names = append(names, tagAncestors(t)...)
+		}
+	}
+	names = uniqueTags(names)
+	sort.Slice(names, func(i, k int) bool { return tagLess(names[i], names[k]) })
+	return names
+}
+
+// choose the paths of the pages of the tags and of the fields
+func (r *siteRenderer) choosePagePaths(entries []Entry) {
+	r.tagPaths = uniquePagePaths(siteTags(entries), tagPagePath)
+	var keys []string
+	for _, f := range summarizeFields(entries) {
+		keys = append(keys, f.key)
+	}
+	r.fieldPaths = uniquePagePaths(keys, fieldPagePath)
+}
+
+// returns the pages of the tags, including the parents of hierarchical tags
+func (r *siteRenderer) renderTags(entries []Entry) (tags []siteLink, e error) {
+	for _, tag := range siteTags(entries) {
+		var tagged []Entry
+		for _, entry := range entries {
+			if entryHasAnyTag(entry, []string{tag}) {
+				tagged = append(tagged, entry)
+			}
+		}
+
+		path := r.tagPaths[tag]
+		page := sitePage{Title: "+" + tag, Entries: r.newSiteEntries(tagged, rootOf(path))}
+		if e := r.render("tag.html", path, page); e != nil {
+			return nil, e
+		}
+		tags = append(tags, siteLink{Name: tag, URL: path, Count: len(tagged)})
+	}
+	return tags, nil
+}
+
+// returns the pages of the fields, with their values
+func (r *siteRenderer) renderFields(entries []Entry) (fields []siteLink, e error) {
+	for _, f := range summarizeFields(entries) {
+		path := r.fieldPaths[f.key]
+		summary := siteFieldSummary{Entries: f.count, Distinct: len(f.values), Numeric: f.numeric}
+		if f.numeric {
+			summary.Min, summary.Max = formatNumber(f.min), formatNumber(f.max)
+			summary.Sum, summary.Mean = formatNumber(f.sum), formatNumber(f.mean)
+		}
+		page := sitePage{Title: "@" + f.key, Summary: &summary}
+		for _, entry := range entries {
+			if value, ok := entry.Fields[f.key]; ok {
+				page.Values = append(page.Values, siteFieldValue{Entry: r.newSiteEntry(entry, rootOf(path)), Value: value})
+			}
+		}
+		if e := r.render("field.html", path, page); e != nil {
+			return nil, e
+		}
+		fields = append(fields, siteLink{Name: f.key, URL: path, Count: f.count})
+	}
+	return fields, nil
+}
+
+// returns a calendar for each year with entries, most recent first
+func siteCalendars(entries []Entry) (calendars []siteCalendar) {
+	years := make(map[time.Time]bool)
+	for _, entry := range entries {
+		year, _ := periodStart(entry.timeObj, "year")
+		years[year] = true
+	}
+
+	var starts []time.Time
+	for year := range years {
+		starts = append(starts, year)
+	}
+	sort.Slice(starts, func(i, k int) bool { return starts[i].After(starts[k]) })
+
+	for _, year := range starts {
+		days, values, max := periodValues(entries, year, "year", "")
+		calendar := siteCalendar{Year: year.Format("2006")}
+
+		// weeks start on monday
+		week := make([]siteDay, (int(year.Weekday())+6)%7)
+		for i, day := range days {
+			d := siteDay{Date: day.Format("2006-01-02"), Count: int(values[i]), Level: intensityLevel(values[i], max)}
+			if d.Count > 0 {
+				d.URL = monthPagePath(day)
+			}
+			week = append(week, d)
+			if len(week) == 7 {
+				calendar.Weeks = append(calendar.Weeks, week)
+				week = nil
+			}
+		}
+		if len(week) > 0 {
+			calendar.Weeks = append(calendar.Weeks, append(week, make([]siteDay, 7-len(week))...))
+		}
+		calendars = append(calendars, calendar)
+	}
+	return calendars
+}
+
+// returns the search index of the entries
+func siteSearchIndex(entries []Entry) ([]byte, error) {
+	documents := make([]searchDocument, 0)
+	for _, entry := range entries {
+		var words []string
+		text := entry.Title + " " + entry.Content
+		for _, span := range wordSpans(text) {
+			words = append(words, foldWord(text[span[0]:span[1]]))
+		}
+		sort.Strings(words)
+		words = uniqueTags(words)
+
+		tags := entry.Tags
+		if tags == nil {
+			tags = make([]string, 0)
+		}
+		documents = append(documents, searchDocument{
+			Title: entry.Title,
+			Date:  entry.timeObj.Format("2006-01-02 15:04"),
+			URL:   monthPagePath(entry.timeObj) + "#entry-" + entry.ID,
+			Tags:  tags,
+			Words: words,
+		})
+	}
+	return json.Marshal(documents)
+}
+
+// build a static website from the entries
+// if themeFolder is empty, the embedded theme is used
+func buildSite(entries []Entry, site, themeFolder string) (files []exportFile, e error) {
+	entries = sortedEntries(publicEntries(entries))
+	if len(entries) == 0 {
+		return nil, errors.New("no entries to publish")
+	}
+
+	var theme fs.FS
+	if themeFolder != "" {
+		theme = os.DirFS(themeFolder)
+	} else if theme, e = fs.Sub(embeddedTheme, "theme"); e != nil {
+		return nil, e
+	}
+
+	r, e := newSiteRenderer(theme, site)
+	if e != nil {
+		return nil, e
+	}
+	r.choosePagePaths(entries)
+
+	years, e := r.renderMonths(entries)
+	if e != nil {
+		return nil, e
+	}
+	tags, e := r.renderTags(entries)
+	if e != nil {
+		return nil, e
+	}
+	fields, e := r.renderFields(entries)
+	if e != nil {
+		return nil, e
+	}
+
+	if e := r.render("index.html", "index.html", sitePage{Title: site, Years: years, Tags: tags, Fields: fields}); e != nil {
+		return nil, e
+	}
+	if e := r.render("calendar.html", "calendar.html", sitePage{Title: "Calendar", Calendars: siteCalendars(entries)}); e != nil {
+		return nil, e
+	}
+	if e := r.render("search.html", "search.html", sitePage{Title: "Search"}); e != nil {
+		return nil, e
+	}
+
+	index, e := siteSearchIndex(entries)
+	if e != nil {
+		return nil, e
+	}
+	// a script and not a JSON file, since browsers don't fetch local files
+	script := append(append([]byte("window.searchIndex = "), index...), []byte(";\n")...)
+	r.files = append(r.files, exportFile{path: "search-index.js", content: script})
+
+	return r.files, nil
+}
+
+// publish the entries as a static website in a folder
+func (j *Journal) publish(entries []Entry, folder, themeFolder string) (written, unchanged int, e error) {
+	files, e := buildSite(entries, strings.TrimSuffix(j.filename, ".json"), themeFolder)
+	if e != nil {
+		return 0, 0, e
+	}
+	return writeExportFiles(folder, files)
+}
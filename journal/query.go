@@ -0,0 +1,476 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// node of the query abstract syntax tree
+type queryNode interface {
+	match(entry Entry) bool
+}
+
+// both children must match
+type andNode struct {
+	left, right queryNode
+}
+
+// at least one of the children must match
+type orNode struct {
+	left, right queryNode
+}
+
+// the child must not match
+type notNode struct {
+	child queryNode
+}
+
+// text contained in title or content (case insensitive)
+type textNode struct {
+	text string
+}
+
+// tag (or one of its descendants) contained in the entry
+type tagNode struct {
+	tag string
+}
+
+// field comparison. An empty operator only checks if the key exists
+type fieldNode struct {
+	key, operator, value string
+}
+
+// entry date between start (inclusive) and end (exclusive)
+// a zero time means that the range is open on that side
+type dateNode struct {
+	start, end time.Time
+}
+
+func (n andNode) match(entry Entry) bool {
+	return n.left.match(entry) && n.right.match(entry)
+}
+
+func (n orNode) match(entry Entry) bool {
+	return n.left.match(entry) || n.right.match(entry)
+}
+
+func (n notNode) match(entry Entry) bool {
+	return !n.child.match(entry)
+}
+
+func (n textNode) match(entry Entry) bool {
+	text := strings.ToLower(n.text)
+	return strings.Contains(strings.ToLower(entry.Title), text) || strings.Contains(strings.ToLower(entry.Content), text)
+}
+
+func (n tagNode) match(entry Entry) bool {
+	return entryHasAnyTag(entry, []string{n.tag})
+}
+
+func (n fieldNode) match(entry Entry) bool {
+	value, ok := entry.Fields[n.key]
+	if !ok {
+		return false
+	}
+	if n.operator == "" {
+		return true
+	}
+
+	// compare as numbers if both sides are numeric, as strings otherwise
+	var comparison int
+	entryNumber, entryOk := parseNumber(value)
+	queryNumber, queryOk := parseNumber(n.value)
+	if entryOk && queryOk {
+		if entryNumber < queryNumber {
+			comparison = -1
+		} else if entryNumber > queryNumber {
+			comparison = 1
+		}
+	} else {
+		comparison = strings.Compare(strings.ToLower(value), strings.ToLower(n.value))
+	}
+
+	switch n.operator {
+	case "=":
+		return comparison == 0
+	case "!=":
+		return comparison != 0
+	case ">":
+		return comparison > 0
+	case ">=":
+		return comparison >= 0
+	case "<":
+		return comparison < 0
+	case "<=":
+		return comparison <= 0
+	}
+	return false
+}
+
+func (n dateNode) match(entry Entry) bool {
+	// only the day is relevant, regardless of the time zone
+	day := time.Date(entry.timeObj.Year(), entry.timeObj.Month(), entry.timeObj.Day(), 0, 0, 0, 0, time.UTC)
+	if !n.start.IsZero() && day.Before(n.start) {
+		return false
+	}
+	if !n.end.IsZero() && !day.Before(n.end) {
+		return false
+	}
+	return true
+}
+
+// single token of a query
+type queryToken struct {
+	value  string
+	quoted bool
+}
+
+// splits a query into tokens
+// parentheses are single tokens, text between quotes is kept together
+func tokenizeQuery(query string) (tokens []queryToken, e error) {
+	runes := []rune(query)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		if unicode.IsSpace(r) {
+			i++
+			continue
+		}
+
+		if r == '(' || r == ')' {
+			tokens = append(tokens, queryToken{value: string(r)})
+			i++
+			continue
+		}
+
+		// read a word, keeping together the text between quotes
+		// a word that starts with a quote is plain text
+		var word strings.Builder
+		var inQuotes bool
+		quoted := r == '"'
+		for ; i < len(runes); i++ {
+			r = runes[i]
+			if r == '"' {
+				inQuotes = !inQuotes
+				continue
+			}
+			if !inQuotes && (unicode.IsSpace(r) || r == '(' || r == ')') {
+				break
+			}
+			word.WriteRune(r)
+		}
+
+		if inQuotes {
+			return nil, errors.New("unterminated quote in query")
+		}
+
+		tokens = append(tokens, queryToken{value: word.String(), quoted: quoted})
+	}
+
+	return tokens, nil
+}
+
+// recursive descent parser for queries
+type queryParser struct {
+	tokens   []queryToken
+	position int
+}
+
+// parses a query into its abstract syntax tree
+// grammar:
+//
+//	or      := and (OR and)*
+//	and     := not ([AND] not)*
+//	not     := NOT not | primary
+//	primary := "(" or ")" | term
+func parseQuery(query string) (node queryNode, e error) {
+	tokens, e := tokenizeQuery(query)
+	if e != nil {
+		return nil, e
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("empty query")
+	}
+
+	p := &queryParser{tokens: tokens}
+	node, e = p.parseOr()
+	if e != nil {
+		return nil, e
+	}
+
+	if p.position < len(p.tokens) {
+		return nil, errors.New("unexpected '" + p.tokens[p.position].value + "' in query")
+	}
+
+	return node, nil
+}
+
+// returns the current token, if it's a keyword
+func (p *queryParser) keyword() string {
+	if p.position >= len(p.tokens) || p.tokens[p.position].quoted {
+		return ""
+	}
+
+	switch p.tokens[p.position].value {
+	case "AND", "OR", "NOT", "(", ")":
+		return p.tokens[p.position].value
+	}
+	return ""
+}
+
+func (p *queryParser) parseOr() (node queryNode, e error) {
+	node, e = p.parseAnd()
+	if e != nil {
+		return nil, e
+	}
+
+	for p.keyword() == "OR" {
+		p.position++
+		right, e := p.parseAnd()
+		if e != nil {
+			return nil, e
+		}
+		node = orNode{left: node, right: right}
+	}
+
+	return node, nil
+}
+
+func (p *queryParser) parseAnd() (node queryNode, e error) {
+	node, e = p.parseNot()
+	if e != nil {
+		return nil, e
+	}
+
+	for p.position < len(p.tokens) {
+		keyword := p.keyword()
+		if keyword == "OR" || keyword == ")" {
+			break
+		}
+		// AND is optional between two terms
+		if keyword == "AND" {
+			p.position++
+		}
+
+		right, e := p.parseNot()
+		if e != nil {
+			return nil, e
+		}
+		node = andNode{left: node, right: right}
+	}
+
+	return node, nil
+}
+
+func (p *queryParser) parseNot() (node queryNode, e error) {
+	if p.keyword() == "NOT" {
+		p.position++
+		child, e := p.parseNot()
+		if e != nil {
+			return nil, e
+		}
+		return notNode{child: child}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *queryParser) parsePrimary() (node queryNode, e error) {
+	if p.position >= len(p.tokens) {
+		return nil, errors.New("unexpected end of query")
+	}
+
+	switch p.keyword() {
+	case "(":
+		p.position++
+		node, e = p.parseOr()
+		if e != nil {
+			return nil, e
+		}
+		if p.keyword() != ")" {
+			return nil, errors.New("missing closing parenthesis in query")
+		}
+		p.position++
+		return node, nil
+	case "":
+		token := p.tokens[p.position]
+		p.position++
+		return parseQueryTerm(token)
+	default:
+		return nil, errors.New("unexpected '" + p.tokens[p.position].value + "' in query")
+	}
+}
+
+// parses a single term of the query
+func parseQueryTerm(token queryToken) (node queryNode, e error) {
+	if token.quoted {
+		return textNode{text: token.value}, nil
+	}
+
+	switch {
+	case strings.HasPrefix(token.value, "tag:"):
+		tag := cleanTag(strings.TrimPrefix(token.value, "tag:"))
+		if tag == "" {
+			return nil, errors.New("tag not provided in query")
+		}
+		return tagNode{tag: tag}, nil
+	case strings.HasPrefix(token.value, "date:"):
+		return parseDateRange(strings.TrimPrefix(token.value, "date:"))
+	case strings.HasPrefix(token.value, "field:"):
+		return parseFieldTerm(strings.TrimPrefix(token.value, "field:"))
+	case strings.HasPrefix(token.value, "@"):
+		return parseFieldTerm(strings.TrimPrefix(token.value, "@"))
+	}
+
+	return textNode{text: token.value}, nil
+}
+
+// parses a field term in format key, key=value, key>value... The operator
+// is the first one in the term, so that values can contain operators
+func parseFieldTerm(term string) (node queryNode, e error) {
+	// two characters operators first, so that >= isn't read as >
+	operators := []string{">=", "<=", "!=", ">", "<", "="}
+
+	position, operator := -1, ""
+	for _, o := range operators {
+		if index := strings.Index(term, o); index != -1 && (position == -1 || index < position) {
+			position, operator = index, o
+		}
+	}
+
+	if position != -1 {
+		node := fieldNode{key: term[:position], operator: operator, value: term[position+len(operator):]}
+		if node.key == "" {
+			return nil, errors.New("field key not provided in query")
+		}
+		return node, nil
+	}
+
+	if term == "" {
+		return nil, errors.New("field key not provided in query")
+	}
+	return fieldNode{key: term}, nil
+}
+
+// parses a date range in format start..end, where each side can be
+// YYYY-MM-DD, YYYY-MM or YYYY and can be omitted. A single date matches
+// the whole day, month or year
+func parseDateRange(dateRange string) (node queryNode, e error) {
+	if dateRange == "" {
+		return nil, errors.New("date not provided in query")
+	}
+
+	if !strings.Contains(dateRange, "..") {
+		start, end, e := parsePeriod(dateRange)
+		if e != nil {
+			return nil, e
+		}
+		return dateNode{start: start, end: end}, nil
+	}
+
+	var n dateNode
+	bounds := strings.SplitN(dateRange, "..", 2)
+	if bounds[0] != "" {
+		n.start, _, e = parsePeriod(bounds[0])
+		if e != nil {
+			return nil, e
+		}
+	}
+	if bounds[1] != "" {
+		_, n.end, e = parsePeriod(bounds[1])
+		if e != nil {
+			return nil, e
+		}
+	}
+
+	return n, nil
+}
+
+// returns the start (inclusive) and the end (exclusive) of the day,
+// month or year described by a string
+func parsePeriod(period string) (start, end time.Time, e error) {
+	start, e = time.Parse("2006-01-02", period)
+	if e == nil {
+		return start, start.AddDate(0, 0, 1), nil
+	}
+	start, e = time.Parse("2006-01", period)
+	if e == nil {
+		return start, start.AddDate(0, 1, 0), nil
+	}
+	start, e = time.Parse("2006", period)
+	if e == nil {
+		return start, start.AddDate(1, 0, 0), nil
+	}
+
+	return time.Time{}, time.Time{}, errors.New("cannot parse date '" + period + "'. Format: YYYY-MM-DD, YYYY-MM or YYYY")
+}
+
+// parses two optional dates in format YYYY-MM-DD into a range (inclusive)
+func parseDateBounds(startTimestamp, endTimestamp string) (between dateNode, e error) {
+	if startTimestamp != "" {
+		between.start, e = time.Parse("2006-01-02", startTimestamp)
+		if e != nil {
+			return between, errors.New("cannot parse start date")
+		}
+	}
+	if endTimestamp != "" {
+		between.end, e = time.Parse("2006-01-02", endTimestamp)
+		if e != nil {
+			return between, errors.New("cannot parse end date")
+		}
+		// the end date is inclusive
+		between.end = between.end.AddDate(0, 0, 1)
+	}
+	return between, nil
+}
+
+// get the entries between two optional dates (inclusive)
+// if no date is provided, all the entries are returned
+func (j *Journal) entriesInRange(startTimestamp, endTimestamp string) (entries []Entry, e error) {
+	return j.filterEntries("", startTimestamp, endTimestamp)
+}
+
+// get the entries matching an optional query, between two optional dates
+func (j *Journal) filterEntries(query, startTimestamp, endTimestamp string) (entries []Entry, e error) {
+	// an open date range matches every entry
+	var node queryNode = dateNode{}
+	if query != "" {
+		node, e = parseQuery(query)
+		if e != nil {
+			return make([]Entry, 0), e
+		}
+	}
+
+	// restrict the query to the provided dates
+	if startTimestamp != "" || endTimestamp != "" {
+		between, e := parseDateBounds(startTimestamp, endTimestamp)
+		if e != nil {
+			return make([]Entry, 0), e
+		}
+		node = andNode{left: node, right: between}
+	}
+
+	entries = make([]Entry, 0)
+	for _, entry := range j.Entries {
+		if node.match(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// get all the entries matching a query, optionally between two dates
+func (j *Journal) queryEntries(query, startTimestamp, endTimestamp string) (entries []Entry, e error) {
+	entries, e = j.filterEntries(query, startTimestamp, endTimestamp)
+	if e != nil {
+		return entries, e
+	}
+
+	if len(entries) > 0 {
+		return entries, nil
+	}
+	return make([]Entry, 0), errors.New("no entries found matching the query")
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFieldTerm(t *testing.T) {
+	tests := []struct {
+		term string
+		want fieldNode
+	}{
+		{"run", fieldNode{key: "run"}},
+		{"run=5", fieldNode{key: "run", operator: "=", value: "5"}},
+		{"run>5", fieldNode{key: "run", operator: ">", value: "5"}},
+		{"run>=5", fieldNode{key: "run", operator: ">=", value: "5"}},
+		{"run<=5", fieldNode{key: "run", operator: "<=", value: "5"}},
+		{"run!=5", fieldNode{key: "run", operator: "!=", value: "5"}},
+		{"note=a>b", fieldNode{key: "note", operator: "=", value: "a>b"}},
+		{"note!=a=b", fieldNode{key: "note", operator: "!=", value: "a=b"}},
+		{"note<a>=b", fieldNode{key: "note", operator: "<", value: "a>=b"}},
+		{"note=", fieldNode{key: "note", operator: "=", value: ""}},
+	}
+
+	for _, test := range tests {
+		node, e := parseFieldTerm(test.term)
+		if e != nil {
+			t.Errorf("parseFieldTerm(%q): unexpected error %v", test.term, e)
+			continue
+		}
+		if node != test.want {
+			t.Errorf("parseFieldTerm(%q) = %+v, want %+v", test.term, node, test.want)
+		}
+	}
+
+	for _, term := range []string{"", "=5", ">5"} {
+		if _, e := parseFieldTerm(term); e == nil {
+			t.Errorf("parseFieldTerm(%q): expected an error", term)
+		}
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	date := func(value string) time.Time {
+		timeObj, _ := time.Parse("2006-01-02", value)
+		return timeObj
+	}
+	entries := []Entry{
+		{ID: "a", Title: "Morning run", Content: "Deploy day", Tags: []string{"work"}, Fields: map[string]string{"run": "5km"}, timeObj: date("2021-03-01")},
+		{ID: "b", Title: "Meeting", Tags: []string{"work/meeting"}, Fields: map[string]string{"run": "12"}, timeObj: date("2021-04-15")},
+		{ID: "c", Title: "Holiday", Tags: []string{"fun"}, Fields: map[string]string{"note": "a>b"}, timeObj: date("2021-07-20")},
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"tag:work", "ab"},
+		{"tag:work AND NOT tag:work/meeting", "a"},
+		{"tag:fun OR @run>10", "bc"},
+		{"@run>=5", "ab"},
+		{"@run<5", ""},
+		{"@run", "ab"},
+		{"@note=a>b", "c"},
+		{"field:note", "c"},
+		{"date:2021-03..2021-06", "ab"},
+		{"date:2021-04", "b"},
+		{"date:..2021-03-31", "a"},
+		{"date:2021-04-15..", "bc"},
+		{`"deploy"`, "a"},
+		{"morning", "a"},
+		{"(tag:fun OR tag:work/meeting) AND NOT holiday", "b"},
+		{"NOT NOT tag:fun", "c"},
+	}
+
+	for _, test := range tests {
+		node, e := parseQuery(test.query)
+		if e != nil {
+			t.Errorf("parseQuery(%q): unexpected error %v", test.query, e)
+			continue
+		}
+		var got string
+		for _, entry := range entries {
+			if node.match(entry) {
+				got += entry.ID
+			}
+		}
+		if got != test.want {
+			t.Errorf("parseQuery(%q) matches %q, want %q", test.query, got, test.want)
+		}
+	}
+
+	for _, query := range []string{"", "(tag:work", "tag:work)", "tag:", "AND tag:work", "tag:work OR", "date:2021-13", `"open`} {
+		if _, e := parseQuery(query); e == nil {
+			t.Errorf("parseQuery(%q): expected an error", query)
+		}
+	}
+}
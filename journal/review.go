@@ -0,0 +1,265 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// number of longest entries shown in a review
+const reviewLongestEntries = 3
+
+// number of entries with a tag, in a period and in the previous one
+type reviewTag struct {
+	Tag      string `json:"tag"`
+	Entries  int    `json:"entries"`
+	Previous int    `json:"previous"`
+}
+
+// values of a field in a period, compared with the previous one
+type reviewField struct {
+	Key     string `json:"key"`
+	Entries int    `json:"entries"`
+	Numeric bool   `json:"numeric"`
+	// only set if the values are numeric
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+	Sum  float64 `json:"sum"`
+	Mean float64 `json:"mean"`
+	// entries and mean in the previous period
+	PreviousEntries int      `json:"previousEntries"`
+	PreviousMean    *float64 `json:"previousMean,omitempty"`
+}
+
+// entry with its number of words
+type reviewEntry struct {
+	Entry
+	Words int `json:"words"`
+}
+
+// totals of the previous period
+type reviewComparison struct {
+	Label   string `json:"label"`
+	Entries int    `json:"entries"`
+	Words   int    `json:"words"`
+	Days    int    `json:"days"`
+}
+
+// digest of the entries of a week, month or year
+type periodReview struct {
+	Period string `json:"period"`
+	Label  string `json:"label"`
+	// first and last day of the period
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// number of days with entries and of days in the period (up to today)
+	Days      int `json:"days"`
+	TotalDays int `json:"totalDays"`
+	Words     int `json:"words"`
+	// days of the period (up to today) without entries
+	EmptyDays []string         `json:"daysWithoutEntries"`
+	Tags      []reviewTag      `json:"tags"`
+	Fields    []reviewField    `json:"fields"`
+	Longest   []reviewEntry    `json:"longestEntries"`
+	Previous  reviewComparison `json:"previous"`
+	Entries   []Entry          `json:"entries"`
+}
+
+// returns the entries in the period starting at start
+func entriesInPeriod(entries []Entry, start time.Time, period string) (found []Entry) {
+	for _, entry := range entries {
+		if entryStart, _ := periodStart(entry.timeObj, period); entryStart.Equal(start) {
+			found = append(found, entry)
+		}
+	}
+	sort.SliceStable(found, func(i, k int) bool { return found[i].timeObj.Before(found[k].timeObj) })
+	return found
+}
+
+// returns the total number of words of the entries
+func totalWords(entries []Entry) (words int) {
+	for _, entry := range entries {
+		words += countWords(entry)
+	}
+	return words
+}
+
+// returns the number of entries with each tag
+func countTags(entries []Entry) map[string]int {
+	counts := make(map[string]int)
+	for _, entry := range entries {
+		for _, t := range entry.Tags {
+			counts[t]++
+		}
+	}
+	return counts
+}
+
+// build the review of the week, month or year containing a date,
+// comparing it with the previous one
+func computeReview(entries []Entry, period string, date, today time.Time) (review periodReview, e error) {
+	if period != "week" && period != "month" && period != "year" {
+		return review, errors.New("unknown period '" + period + "'. Periods: week, month, year")
+	}
+
+	start, _ := periodStart(date, period)
+	end := nextPeriod(start, period)
+	previousStart, _ := periodStart(start.AddDate(0, 0, -1), period)
+
+	current := entriesInPeriod(entries, start, period)
+	previous := entriesInPeriod(entries, previousStart, period)
+	if len(current) == 0 {
+		return review, errors.New("no entries found in " + periodLabel(start, period))
+	}
+
+	review.Period = period
+	review.Label = periodLabel(start, period)
+	review.Start = start.Format("2006-01-02")
+	review.End = end.AddDate(0, 0, -1).Format("2006-01-02")
+	review.Entries = current
+	review.Words = totalWords(current)
+
+	// find the days without entries, ignoring the days in the future
+	days := make(map[time.Time]bool)
+	for _, day := range entryDays(current) {
+		days[day] = true
+	}
+	review.Days = len(days)
+	review.EmptyDays = make([]string, 0)
+	today, _ = periodStart(today, "day")
+	for day := start; day.Before(end) && !day.After(today); day = day.AddDate(0, 0, 1) {
+		review.TotalDays++
+		if !days[day] {
+			review.EmptyDays = append(review.EmptyDays, day.Format("2006-01-02"))
+		}
+	}
+
+	// tags, most used first
+	previousTags := countTags(previous)
+	review.Tags = make([]reviewTag, 0)
+	for _, t := range rankCounts(countTags(current), 0) {
+		review.Tags = append(review.Tags, reviewTag{Tag: t.name, Entries: t.count, Previous: previousTags[t.name]})
+	}
+
+	// fields, sorted by key
+	previousFields := make(map[string]fieldSummary)
+	for _, f := range summarizeFields(previous) {
+		previousFields[f.key] = f
+	}
+	review.Fields = make([]reviewField, 0)
+	for _, f := range summarizeFields(current) {
+		field := reviewField{Key: f.key, Entries: f.count, Numeric: f.numeric}
+		if f.numeric {
+			field.Min, field.Max, field.Sum, field.Mean = f.min, f.max, f.sum, f.mean
+		}
+		if p, ok := previousFields[f.key]; ok {
+			field.PreviousEntries = p.count
+			if f.numeric && p.numeric {
+				mean := p.mean
+				field.PreviousMean = &mean
+			}
+		}
+		review.Fields = append(review.Fields, field)
+	}
+
+	// longest entries
+	for _, entry := range current {
+		review.Longest = append(review.Longest, reviewEntry{Entry: entry, Words: countWords(entry)})
+	}
+	sort.SliceStable(review.Longest, func(i, k int) bool { return review.Longest[i].Words > review.Longest[k].Words })
+	if len(review.Longest) > reviewLongestEntries {
+		review.Longest = review.Longest[:reviewLongestEntries]
+	}
+
+	review.Previous = reviewComparison{
+		Label:   periodLabel(previousStart, period),
+		Entries: len(previous),
+		Words:   totalWords(previous),
+		Days:    len(entryDays(previous)),
+	}
+
+	return review, nil
+}
+
+// formats the difference between two values (e.g. +3)
+func formatDelta(current, previous float64) string {
+	delta := current - previous
+	if delta > 0 {
+		return "+" + formatNumber(delta)
+	}
+	return formatNumber(delta)
+}
+
+// returns the review formatted as Markdown
+func (r periodReview) markdown() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Review of %s\n\n", r.Label)
+	fmt.Fprintf(&b, "From %s to %s\n\n", r.Start, r.End)
+
+	// summary
+	b.WriteString("## Summary\n\n")
+	b.WriteString("| | " + r.Label + " | " + r.Previous.Label + " | Change |\n")
+	b.WriteString("| --- | --- | --- | --- |\n")
+	fmt.Fprintf(&b, "| Entries | %d | %d | %s |\n", len(r.Entries), r.Previous.Entries, formatDelta(float64(len(r.Entries)), float64(r.Previous.Entries)))
+	fmt.Fprintf(&b, "| Words | %d | %d | %s |\n", r.Words, r.Previous.Words, formatDelta(float64(r.Words), float64(r.Previous.Words)))
+	fmt.Fprintf(&b, "| Days with entries | %d | %d | %s |\n\n", r.Days, r.Previous.Days, formatDelta(float64(r.Days), float64(r.Previous.Days)))
+
+	// tags
+	if len(r.Tags) > 0 {
+		b.WriteString("## Tags\n\n")
+		b.WriteString("| Tag | Entries | Previous |\n")
+		b.WriteString("| --- | --- | --- |\n")
+		for _, t := range r.Tags {
+			fmt.Fprintf(&b, "| +%s | %d | %d |\n", t.Tag, t.Entries, t.Previous)
+		}
+		b.WriteString("\n")
+	}
+
+	// fields
+	if len(r.Fields) > 0 {
+		b.WriteString("## Fields\n\n")
+		b.WriteString("| Field | Entries | Min | Max | Sum | Mean | Previous mean |\n")
+		b.WriteString("| --- | --- | --- | --- | --- | --- | --- |\n")
+		for _, f := range r.Fields {
+			fmt.Fprintf(&b, "| @%s | %d |", f.Key, f.Entries)
+			if f.Numeric {
+				fmt.Fprintf(&b, " %s | %s | %s | %s |", formatNumber(f.Min), formatNumber(f.Max), formatNumber(f.Sum), formatNumber(f.Mean))
+			} else {
+				b.WriteString(" | | | |")
+			}
+			if f.PreviousMean != nil {
+				fmt.Fprintf(&b, " %s |\n", formatNumber(*f.PreviousMean))
+			} else {
+				b.WriteString(" |\n")
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	// longest entries
+	b.WriteString("## Longest entries\n\n")
+	for i, entry := range r.Longest {
+		fmt.Fprintf(&b, "%d. **%s** (%s), %d words\n", i+1, entry.Title, entry.Timestamp, entry.Words)
+	}
+	b.WriteString("\n")
+
+	// days without entries
+	if len(r.EmptyDays) > 0 {
+		b.WriteString("## Days without entries\n\n")
+		for _, day := range r.EmptyDays {
+			b.WriteString("- " + day + "\n")
+		}
+		b.WriteString("\n")
+	}
+
+	// entries
+	b.WriteString("## Entries\n\n")
+	for _, entry := range r.Entries {
+		writeMarkdownEntry(&b, entry, "### "+entry.Timestamp)
+	}
+
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Revision is a previous version of an entry, replaced by a change
+type Revision struct {
+	// when the version was replaced and by which command
+	Time      string            `json:"time"`
+	Operation string            `json:"operation"`
+	Title     string            `json:"title"`
+	Content   string            `json:"content"`
+	Tags      []string          `json:"tags"`
+	Fields    map[string]string `json:"fields"`
+}
+
+// difference between two versions of an entry
+type revisionDiff struct {
+	// title, content, tags or fields
+	attribute string
+	// lines starting with "- " (removed), "+ " (added) or "  " (unchanged)
+	lines []string
+}
+
+// returns the version of an entry as a revision
+func entryRevision(entry Entry) Revision {
+	revision := Revision{
+		Title:   entry.Title,
+		Content: entry.Content,
+		Tags:    append([]string{}, entry.Tags...),
+		Fields:  make(map[string]string),
+	}
+	for k, v := range entry.Fields {
+		revision.Fields[k] = v
+	}
+	return revision
+}
+
+// returns the fields as sorted key=value lines
+func fieldLines(fields map[string]string) (lines []string) {
+	for _, k := range sortedFieldKeys(fields) {
+		lines = append(lines, k+"="+fields[k])
+	}
+	return lines
+}
+
+// returns the difference between two lists of lines, keeping their order
+func lineDiff(before, after []string) (lines []string) {
+	// longest common subsequence
+	common := make([][]int, len(before)+1)
+	for i := range common {
+		common[i] = make([]int, len(after)+1)
+	}
+	for i := len(before) - 1; i >= 0; i-- {
+		for k := len(after) - 1; k >= 0; k-- {
+			if before[i] == after[k] {
+				common[i][k] = common[i+1][k+1] + 1
+			} else if common[i+1][k] >= common[i][k+1] {
+				common[i][k] = common[i+1][k]
+			} else {
+				common[i][k] = common[i][k+1]
+			}
+		}
+	}
+
+	i, k := 0, 0
+	for i < len(before) || k < len(after) {
+		switch {
+		case i < len(before) && k < len(after) && before[i] == after[k]:
+			lines = append(lines, "  "+before[i])
+			i++
+			k++
+		case k == len(after) || (i < len(before) && common[i+1][k] >= common[i][k+1]):
+			lines = append(lines, "- "+before[i])
+			i++
+		default:
+			lines = append(lines, "+ "+after[k])
+			k++
+		}
+	}
+	return lines
+}
+
+// returns the differences between two versions of an entry
+func diffRevisions(before, after Revision) (diffs []revisionDiff) {
+	compare := func(attribute string, b, a []string) {
+		if strings.Join(b, "\n") != strings.Join(a, "\n") || len(b) != len(a) {
+			diffs = append(diffs, revisionDiff{attribute: attribute, lines: lineDiff(b, a)})
+		}
+	}
+
+	compare("title", []string{before.Title}, []string{after.Title})
+	compare("content", strings.Split(before.Content, "\n"), strings.Split(after.Content, "\n"))
+	compare("tags", before.Tags, after.Tags)
+	compare("fields", fieldLines(before.Fields), fieldLines(after.Fields))
+	return diffs
+}
+
+// record the previous version of the entries changed by a command, updating
+// the time of their last change. The history of entries that don't exist
+// anymore is removed
+func (j *Journal) recordRevisions(state UndoState) {
+	now := time.Now().Format(time.RFC3339)
+	for _, previous := range state.Entries {
+		position, found := j.findEntryByID(previous.ID)
+		if !found {
+			continue
+		}
+
+		revision := entryRevision(previous)
+		if len(diffRevisions(revision, entryRevision(j.Entries[position]))) == 0 {
+			continue
+		}
+		revision.Time, revision.Operation = now, state.Operation
+		j.Entries[position].Updated = time.Now().Format(j.timeFormat)
+		if j.History == nil {
+			j.History = make(map[string][]Revision)
+		}
+		j.History[previous.ID] = append(j.History[previous.ID], revision)
+	}
+
+	// entries in the trash can still be restored
+	for id := range j.History {
+		_, found := j.findEntryByID(id)
+		for _, t := range j.Trash {
+			found = found || t.Entry.ID == id
+		}
+		if !found {
+			delete(j.History, id)
+		}
+	}
+}
+
+// returns the entry with an ID and its previous versions, oldest first
+func (j *Journal) entryHistory(id string) (entry Entry, revisions []Revision, e error) {
+	position, found := j.findEntryByID(id)
+	if !found {
+		return Entry{}, nil, errors.New("cannot find entry with id " + id)
+	}
+	if len(j.History[id]) == 0 {
+		return Entry{}, nil, errors.New("the entry has never been changed")
+	}
+	return j.Entries[position], j.History[id], nil
+}
+
+// bring an entry back to one of its previous versions (numbered from 1).
+// The current version is kept in the history, so this can be reverted too
+func (j *Journal) revertEntry(id, number string) (e error) {
+	entry, revisions, e := j.entryHistory(id)
+	if e != nil {
+		return e
+	}
+	n, e := strconv.Atoi(number)
+	if e != nil || n < 1 || n > len(revisions) {
+		return errors.New("revision '" + number + "' not found. The entry has " + strconv.Itoa(len(revisions)) + " revisions")
+	}
+
+	// copy the revision, so that the history isn't changed with the entry
+	r := revisions[n-1]
+	previous := entryRevision(Entry{Title: r.Title, Content: r.Content, Tags: r.Tags, Fields: r.Fields})
+	entry.Title, entry.Content, entry.Tags, entry.Fields = previous.Title, previous.Content, previous.Tags, previous.Fields
+	if len(entry.Tags) == 0 {
+		entry.Tags = nil
+	}
+
+	position, _ := j.findEntryByID(id)
+	j.Entries[position] = entry
+	return nil
+}
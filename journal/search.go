@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+)
+
+// returns the start and the end of each match in a text
+type textMatcher func(text string) [][2]int
+
+// returns a matcher for a regular expression (RE2 syntax)
+func regexMatcher(expression string) (matcher textMatcher, e error) {
+	re, e := regexp.Compile(expression)
+	if e != nil {
+		return nil, errors.New("invalid regular expression '" + expression + "'")
+	}
+
+	return func(text string) (matches [][2]int) {
+		for _, m := range re.FindAllStringIndex(text, -1) {
+			// empty matches can't be highlighted
+			if m[1] > m[0] {
+				matches = append(matches, [2]int{m[0], m[1]})
+			}
+		}
+		return matches
+	}, nil
+}
+
+// returns a matcher for the words that are similar to any of the keywords.
+// Case and accents are ignored
+func fuzzyMatcher(keywords []string) textMatcher {
+	var folded [][]rune
+	for _, k := range keywords {
+		for _, s := range wordSpans(k) {
+			folded = append(folded, []rune(foldWord(k[s[0]:s[1]])))
+		}
+	}
+
+	return func(text string) (matches [][2]int) {
+		for _, s := range wordSpans(text) {
+			word := []rune(foldWord(text[s[0]:s[1]]))
+			for _, k := range folded {
+				if editDistance(word, k) <= maxEditDistance(k) {
+					matches = append(matches, s)
+					break
+				}
+			}
+		}
+		return matches
+	}
+}
+
+// number of typos allowed for a keyword, depending on its length
+func maxEditDistance(keyword []rune) int {
+	switch {
+	case len(keyword) <= 2:
+		return 0
+	case len(keyword) <= 5:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// edit distance between two words, counting insertions, deletions,
+// substitutions and transpositions of adjacent letters as one edit
+func editDistance(a, b []rune) int {
+	// last three rows of the distance matrix
+	beforePrevious := make([]int, len(b)+1)
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for k := range previous {
+		previous[k] = k
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for k := 1; k <= len(b); k++ {
+			cost := 1
+			if a[i-1] == b[k-1] {
+				cost = 0
+			}
+			// deletion, insertion or substitution
+			current[k] = previous[k] + 1
+			if current[k-1]+1 < current[k] {
+				current[k] = current[k-1] + 1
+			}
+			if previous[k-1]+cost < current[k] {
+				current[k] = previous[k-1] + cost
+			}
+			// transposition
+			if i > 1 && k > 1 && a[i-1] == b[k-2] && a[i-2] == b[k-1] && beforePrevious[k-2]+1 < current[k] {
+				current[k] = beforePrevious[k-2] + 1
+			}
+		}
+		beforePrevious, previous, current = previous, current, beforePrevious
+	}
+
+	return previous[len(b)]
+}
+
+// check if the title, the content or the fields of an entry match
+func entryMatches(entry Entry, matcher textMatcher) bool {
+	if len(matcher(entry.Title)) > 0 || len(matcher(entry.Content)) > 0 {
+		return true
+	}
+	for k, v := range entry.Fields {
+		if len(matcher(k)) > 0 || len(matcher(v)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// get all the entries matching a matcher
+func (j *Journal) searchMatching(matcher textMatcher) (entries []Entry, e error) {
+	for _, entry := range j.Entries {
+		if entryMatches(entry, matcher) {
+			entries = append(entries, entry)
+		}
+	}
+
+	if len(entries) > 0 {
+		return entries, nil
+	}
+	return make([]Entry, 0), errors.New("no entries found matching the search")
+}
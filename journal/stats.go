@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// number of items shown in the rankings
+const statsRankingSize = 5
+
+// streak of consecutive days with entries
+type streak struct {
+	start, end time.Time
+	days       int
+}
+
+// item of a ranking (tag, field, weekday...) with its count
+type rankedItem struct {
+	name  string
+	count int
+}
+
+// statistics about the entries of a journal
+type journalStats struct {
+	entries     int
+	first, last time.Time
+	// number of distinct days, weeks and months with entries
+	days, weeks, months int
+	// number of days, weeks and months between the first and the last entry
+	spanDays, spanWeeks, spanMonths int
+	current, longest                streak
+	words                           int
+	longestEntry                    Entry
+	longestEntryWords               int
+	tags, fields, weekdays, hours   []rankedItem
+}
+
+// returns the number of words in an entry
+func countWords(entry Entry) int {
+	return len(strings.Fields(entry.Title + " " + entry.Content))
+}
+
+// sorts the counts into a ranking, most used first
+func rankCounts(counts map[string]int, size int) (ranking []rankedItem) {
+	for name, count := range counts {
+		ranking = append(ranking, rankedItem{name: name, count: count})
+	}
+	sort.Slice(ranking, func(i, k int) bool {
+		if ranking[i].count != ranking[k].count {
+			return ranking[i].count > ranking[k].count
+		}
+		return ranking[i].name < ranking[k].name
+	})
+
+	if size > 0 && len(ranking) > size {
+		ranking = ranking[:size]
+	}
+	return ranking
+}
+
+// returns the distinct days with entries, in chronological order
+func entryDays(entries []Entry) (days []time.Time) {
+	seen := make(map[time.Time]bool)
+	for _, entry := range entries {
+		day, _ := periodStart(entry.timeObj, "day")
+		if !seen[day] {
+			seen[day] = true
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, k int) bool { return days[i].Before(days[k]) })
+	return days
+}
+
+// returns the current and the longest streaks of consecutive days.
+// The current streak is still going if its last day is today or yesterday
+func findStreaks(days []time.Time, today time.Time) (current, longest streak) {
+	var s streak
+	for i, day := range days {
+		if i > 0 && day.Equal(days[i-1].AddDate(0, 0, 1)) {
+			s.end = day
+			s.days++
+		} else {
+			s = streak{start: day, end: day, days: 1}
+		}
+
+		if s.days > longest.days {
+			longest = s
+		}
+	}
+
+	today, _ = periodStart(today, "day")
+	if s.days > 0 && !s.end.Before(today.AddDate(0, 0, -1)) {
+		current = s
+	}
+
+	return current, longest
+}
+
+// returns the number of periods between two dates (both included)
+func countPeriods(first, last time.Time, period string) (count int) {
+	start, _ := periodStart(first, period)
+	end, _ := periodStart(last, period)
+	for p := start; !p.After(end); p = nextPeriod(p, period) {
+		count++
+	}
+	return count
+}
+
+// compute the statistics of the entries
+func computeStats(entries []Entry, today time.Time) (stats journalStats, e error) {
+	if len(entries) == 0 {
+		return stats, errors.New("no entries found")
+	}
+
+	tags := make(map[string]int)
+	fields := make(map[string]int)
+	weekdays := make(map[string]int)
+	hours := make(map[string]int)
+	weeks := make(map[time.Time]bool)
+	months := make(map[time.Time]bool)
+
+	stats.entries = len(entries)
+	stats.first = entries[0].timeObj
+	stats.last = entries[0].timeObj
+
+	for _, entry := range entries {
+		if entry.timeObj.Before(stats.first) {
+			stats.first = entry.timeObj
+		}
+		if entry.timeObj.After(stats.last) {
+			stats.last = entry.timeObj
+		}
+
+		words := countWords(entry)
+		stats.words += words
+		if words > stats.longestEntryWords {
+			stats.longestEntry = entry
+			stats.longestEntryWords = words
+		}
+
+		for _, t := range entry.Tags {
+			tags[t]++
+		}
+		for k := range entry.Fields {
+			fields[k]++
+		}
+		weekdays[entry.timeObj.Weekday().String()]++
+		hours[entry.timeObj.Format("15:00")]++
+
+		week, _ := periodStart(entry.timeObj, "week")
+		weeks[week] = true
+		month, _ := periodStart(entry.timeObj, "month")
+		months[month] = true
+	}
+
+	days := entryDays(entries)
+	stats.days = len(days)
+	stats.weeks = len(weeks)
+	stats.months = len(months)
+	stats.spanDays = countPeriods(stats.first, stats.last, "day")
+	stats.spanWeeks = countPeriods(stats.first, stats.last, "week")
+	stats.spanMonths = countPeriods(stats.first, stats.last, "month")
+	stats.current, stats.longest = findStreaks(days, today)
+
+	stats.tags = rankCounts(tags, statsRankingSize)
+	stats.fields = rankCounts(fields, statsRankingSize)
+	stats.weekdays = rankCounts(weekdays, 0)
+	stats.hours = rankCounts(hours, statsRankingSize)
+
+	return stats, nil
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+// separator between the levels of hierarchical tags (e.g. work/clientA/billing)
+const tagSeparator = "/"
+
+// removes empty levels from a tag
+func cleanTag(tag string) string {
+	var levels []string
+	for _, l := range strings.Split(tag, tagSeparator) {
+		if l != "" {
+			levels = append(levels, l)
+		}
+	}
+	return strings.Join(levels, tagSeparator)
+}
+
+// check if a tag is equal to another one or one of its descendants
+func tagMatches(tag, parent string) bool {
+	return tag == parent || strings.HasPrefix(tag, parent+tagSeparator)
+}
+
+// returns the tag and all of its parents, starting from the root
+// e.g. work/clientA -> work, work/clientA
+func tagAncestors(tag string) (ancestors []string) {
+	levels := strings.Split(tag, tagSeparator)
+	for i := range levels {
+		ancestors = append(ancestors, strings.Join(levels[:i+1], tagSeparator))
+	}
+	return ancestors
+}
+
+// compares two tags level by level, so that each tag is followed by its children
+func tagLess(a, b string) bool {
+	aLevels := strings.Split(a, tagSeparator)
+	bLevels := strings.Split(b, tagSeparator)
+	for i := 0; i < len(aLevels) && i < len(bLevels); i++ {
+		if aLevels[i] != bLevels[i] {
+			return aLevels[i] < bLevels[i]
+		}
+	}
+	return len(aLevels) < len(bLevels)
+}
+
+// removes duplicate tags, keeping the order
+func uniqueTags(tags []string) (unique []string) {
+	seen := make(map[string]bool)
+	for _, t := range tags {
+		if !seen[t] {
+			seen[t] = true
+			unique = append(unique, t)
+		}
+	}
+	return unique
+}
+
+// returns the entries using a tag or one of its descendants
+func (j *Journal) entriesWithTag(tag string) (entries []Entry) {
+	tag = cleanTag(tag)
+	for _, entry := range j.Entries {
+		if entryHasAnyTag(entry, []string{tag}) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// replace a tag and all of its descendants with a new tag in every entry
+// and in the aliases. Returns the number of changed entries
+func (j *Journal) moveTag(oldTag, newTag string) (changed int) {
+	for i := range j.Entries {
+		entry := &j.Entries[i]
+		moved := false
+		for k, t := range entry.Tags {
+			if tagMatches(t, oldTag) {
+				entry.Tags[k] = newTag + strings.TrimPrefix(t, oldTag)
+				moved = true
+			}
+		}
+
+		if moved {
+			entry.Tags = uniqueTags(entry.Tags)
+			changed++
+		}
+	}
+
+	// aliases pointing to the old tag now point to the new one
+	for alias, target := range j.TagAliases {
+		if tagMatches(target, oldTag) {
+			j.TagAliases[alias] = newTag + strings.TrimPrefix(target, oldTag)
+		}
+	}
+
+	return changed
+}
+
+// check the tags passed to rename and merge
+func checkTagPair(oldTag, newTag string) (e error) {
+	if oldTag == "" || newTag == "" {
+		return errors.New("tag not provided correctly")
+	}
+	if tagMatches(newTag, oldTag) {
+		return errors.New("a tag can't be moved into itself or one of its descendants")
+	}
+	return nil
+}
+
+// check that a tag can be renamed
+func (j *Journal) checkRenameTag(oldTag, newTag string) (e error) {
+	oldTag = cleanTag(oldTag)
+	newTag = cleanTag(newTag)
+	if e = checkTagPair(oldTag, newTag); e != nil {
+		return e
+	}
+	if len(j.entriesWithTag(newTag)) > 0 {
+		return errors.New("tag '" + newTag + "' already exists. Use --mergetags to merge the two tags")
+	}
+	if len(j.entriesWithTag(oldTag)) == 0 {
+		return errors.New("tag not found")
+	}
+	return nil
+}
+
+// rename a tag and all of its descendants in every entry
+// returns the number of changed entries
+func (j *Journal) renameTag(oldTag, newTag string) (changed int, e error) {
+	if e = j.checkRenameTag(oldTag, newTag); e != nil {
+		return 0, e
+	}
+	return j.moveTag(cleanTag(oldTag), cleanTag(newTag)), nil
+}
+
+// check that a tag can be merged into another tag
+func (j *Journal) checkMergeTags(fromTag, toTag string) (e error) {
+	fromTag = cleanTag(fromTag)
+	toTag = cleanTag(toTag)
+	if e = checkTagPair(fromTag, toTag); e != nil {
+		return e
+	}
+	if len(j.entriesWithTag(fromTag)) == 0 {
+		return errors.New("tag not found")
+	}
+	return nil
+}
+
+// merge a tag (and all of its descendants) into another tag
+// returns the number of changed entries
+func (j *Journal) mergeTags(fromTag, toTag string) (changed int, e error) {
+	if e = j.checkMergeTags(fromTag, toTag); e != nil {
+		return 0, e
+	}
+	return j.moveTag(cleanTag(fromTag), cleanTag(toTag)), nil
+}
+
+// check that a tag can be deleted
+func (j *Journal) checkDeleteTag(tag string) (e error) {
+	tag = cleanTag(tag)
+	if tag == "" {
+		return errors.New("tag not provided correctly")
+	}
+	if len(j.entriesWithTag(tag)) == 0 {
+		return errors.New("tag not found")
+	}
+	return nil
+}
+
+// remove a tag and all of its descendants from every entry
+// returns the number of changed entries
+func (j *Journal) deleteTag(tag string) (changed int, e error) {
+	if e = j.checkDeleteTag(tag); e != nil {
+		return 0, e
+	}
+	tag = cleanTag(tag)
+
+	for i := range j.Entries {
+		entry := &j.Entries[i]
+		var kept []string
+		for _, t := range entry.Tags {
+			if !tagMatches(t, tag) {
+				kept = append(kept, t)
+			}
+		}
+
+		if len(kept) != len(entry.Tags) {
+			entry.Tags = kept
+			changed++
+		}
+	}
+
+	return changed, nil
+}
+
+// check that an alias can be set
+func (j *Journal) checkTagAlias(alias, target string) (e error) {
+	alias = cleanTag(alias)
+	target = cleanTag(target)
+	if e = checkTagPair(alias, target); e != nil {
+		return e
+	}
+	if _, ok := j.TagAliases[target]; ok {
+		return errors.New("tag '" + target + "' is an alias itself")
+	}
+	for a, t := range j.TagAliases {
+		if t == alias {
+			return errors.New("tag '" + alias + "' is already the target of the alias '" + a + "'")
+		}
+	}
+	return nil
+}
+
+// set an alias, replaced by its target when adding new entries
+func (j *Journal) setTagAlias(alias, target string) (e error) {
+	if e = j.checkTagAlias(alias, target); e != nil {
+		return e
+	}
+	alias = cleanTag(alias)
+	target = cleanTag(target)
+
+	if j.TagAliases == nil {
+		j.TagAliases = make(map[string]string)
+	}
+	j.TagAliases[alias] = target
+	return nil
+}
+
+// remove an alias
+func (j *Journal) removeTagAlias(alias string) (e error) {
+	alias = cleanTag(alias)
+	if _, ok := j.TagAliases[alias]; !ok {
+		return errors.New("alias not found")
+	}
+	delete(j.TagAliases, alias)
+	return nil
+}
+
+// replace the aliases (and their descendants) with their targets
+func (j *Journal) applyTagAliases(tags []string) []string {
+	for i, t := range tags {
+		// the most specific alias wins
+		longest := ""
+		for alias := range j.TagAliases {
+			if tagMatches(t, alias) && len(alias) > len(longest) {
+				longest = alias
+			}
+		}
+		if longest != "" {
+			tags[i] = j.TagAliases[longest] + strings.TrimPrefix(t, longest)
+		}
+	}
+	return uniqueTags(tags)
+}
@@ -0,0 +1,49 @@
+// search the entries with the index built when publishing.
+// Words are matched by prefix, ignoring case and accents
+(function () {
+  var input = document.getElementById("search");
+  var results = document.getElementById("results");
+  // the index is loaded by search-index.js, so that the search works
+  // when the site is opened from a folder, without a server
+  var documents = window.searchIndex || [];
+
+  function fold(text) {
+    return text.normalize("NFD").replace(/[\u0300-\u036f]/g, "").toLowerCase();
+  }
+
+  function matches(doc, terms) {
+    return terms.every(function (term) {
+      if (term.charAt(0) === "+") {
+        return doc.tags.some(function (tag) {
+          return fold(tag) === term.slice(1) || fold(tag).indexOf(term.slice(1) + "/") === 0;
+        });
+      }
+      return doc.words.some(function (word) {
+        return word.indexOf(term) === 0;
+      });
+    });
+  }
+
+  function show() {
+    var terms = fold(input.value).split(/\s+/).filter(Boolean);
+    results.innerHTML = "";
+    if (terms.length === 0) {
+      return;
+    }
+
+    documents.filter(function (doc) {
+      return matches(doc, terms);
+    }).forEach(function (doc) {
+      var item = document.createElement("li");
+      var link = document.createElement("a");
+      link.href = doc.url;
+      link.textContent = doc.title;
+      item.appendChild(link);
+      item.appendChild(document.createTextNode(" " + doc.date));
+      results.appendChild(item);
+    });
+  }
+
+  input.addEventListener("input", show);
+  show();
+})();
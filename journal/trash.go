@@ -0,0 +1,357 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// default number of days the removed entries are kept in the trash
+const defaultTrashRetention = 30
+
+// TrashedEntry is an entry removed from the journal, kept in the trash
+// until it's restored or its retention period ends
+type TrashedEntry struct {
+	Entry   Entry  `json:"entry"`
+	Removed string `json:"removed"`
+}
+
+// UndoState contains what's needed to bring the journal back to its state
+// before the last change: only the entries touched by the change are kept
+type UndoState struct {
+	// command that changed the journal
+	Operation string `json:"operation"`
+	Time      string `json:"time"`
+	// entries changed or removed, as they were before the change
+	Entries []Entry `json:"entries,omitempty"`
+	// IDs of the entries added by the change
+	Added []string `json:"added,omitempty"`
+	// entries taken out of the trash and IDs of the entries put in it
+	Trash   []TrashedEntry `json:"trash,omitempty"`
+	Trashed []string       `json:"trashed,omitempty"`
+	// previous versions of the entries above, as they were before the
+	// change. A missing list is restored as missing
+	History  map[string][]Revision `json:"history,omitempty"`
+	Settings UndoSettings          `json:"settings"`
+}
+
+// UndoSettings are the settings of the journal that can be undone
+type UndoSettings struct {
+	TagAliases     map[string]string `json:"tagAliases,omitempty"`
+	FieldSchema    FieldSchema       `json:"fieldSchema,omitempty"`
+	DailyOnThisDay bool              `json:"dailyOnThisDay,omitempty"`
+	Lookbacks      *[]string         `json:"lookbacks,omitempty"`
+	TrashRetention int               `json:"trashRetention,omitempty"`
+}
+
+// state of the journal before a command, kept in memory while it runs
+type journalState struct {
+	entries  []Entry
+	trash    []TrashedEntry
+	history  map[string][]Revision
+	settings UndoSettings
+}
+
+// returns the number of days the removed entries are kept
+func (j *Journal) trashRetention() int {
+	if j.TrashRetention > 0 {
+		return j.TrashRetention
+	}
+	return defaultTrashRetention
+}
+
+// returns the entries that are not in the cleaned slice
+func removedEntries(entries, cleanEntries []Entry) (removed []Entry) {
+	kept := make(map[string]bool)
+	for _, entry := range cleanEntries {
+		kept[entry.ID] = true
+	}
+	for _, entry := range entries {
+		if !kept[entry.ID] {
+			removed = append(removed, entry)
+		}
+	}
+	return removed
+}
+
+// move entries to the trash
+func (j *Journal) trashEntries(entries []Entry) {
+	removed := time.Now().Format(time.RFC3339)
+	for _, entry := range entries {
+		j.Trash = append(j.Trash, TrashedEntry{Entry: entry, Removed: removed})
+	}
+}
+
+// permanently remove the entries trashed before the retention period
+func (j *Journal) purgeTrash(now time.Time) (purged int) {
+	limit := now.AddDate(0, 0, -j.trashRetention())
+	kept := make([]TrashedEntry, 0)
+	for _, t := range j.Trash {
+		if removed, e := time.Parse(time.RFC3339, t.Removed); e == nil && removed.Before(limit) {
+			purged++
+			continue
+		}
+		kept = append(kept, t)
+	}
+	j.Trash = kept
+	return purged
+}
+
+// permanently remove all the entries in the trash
+func (j *Journal) emptyTrash() (removed int) {
+	removed = len(j.Trash)
+	j.Trash = make([]TrashedEntry, 0)
+	return removed
+}
+
+// move entries from the trash back to the journal, given their IDs or "all".
+// Entries whose ID was used in the meantime get a new one
+func (j *Journal) restoreEntries(ids []string) (restored []Entry, e error) {
+	if len(ids) == 0 {
+		return nil, errors.New("provide the IDs of the entries to restore or all")
+	}
+
+	all := len(ids) == 1 && ids[0] == "all"
+	wanted := make(map[string]bool)
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	kept := make([]TrashedEntry, 0)
+	for _, t := range j.Trash {
+		if !all && !wanted[t.Entry.ID] {
+			kept = append(kept, t)
+			continue
+		}
+		delete(wanted, t.Entry.ID)
+
+		entry := t.Entry
+		if _, found := j.findEntryByID(entry.ID); found {
+			entry.ID = j.newEntryID()
+		}
+		j.Entries = append(j.Entries, entry)
+		restored = append(restored, entry)
+	}
+
+	if !all && len(wanted) > 0 {
+		var missing []string
+		for id := range wanted {
+			missing = append(missing, id)
+		}
+		sort.Strings(missing)
+		// nothing is restored if an ID is wrong
+		j.Entries = j.Entries[:len(j.Entries)-len(restored)]
+		return nil, errors.New("entries not found in the trash: " + strings.Join(missing, ", "))
+	}
+	if len(restored) == 0 {
+		return nil, errors.New("the trash is empty")
+	}
+
+	j.Trash = kept
+	sort.Slice(j.Entries, func(i, k int) bool { return j.Entries[i].timeObj.Before(j.Entries[k].timeObj) })
+	return restored, nil
+}
+
+// returns a copy of the entry, so that later changes don't alter it
+func copyEntry(entry Entry) Entry {
+	entry.Tags = append([]string(nil), entry.Tags...)
+	fields := make(map[string]string)
+	for k, v := range entry.Fields {
+		fields[k] = v
+	}
+	entry.Fields = fields
+	return entry
+}
+
+// returns true if two versions of an entry are the same
+func sameEntry(a, b Entry) bool {
+	a.timeObj, b.timeObj = time.Time{}, time.Time{}
+	if len(a.Tags) == 0 && len(b.Tags) == 0 {
+		a.Tags, b.Tags = nil, nil
+	}
+	if len(a.Fields) == 0 && len(b.Fields) == 0 {
+		a.Fields, b.Fields = nil, nil
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// returns a copy of the settings that can be undone
+func (j *Journal) undoSettings() (settings UndoSettings) {
+	settings = UndoSettings{
+		DailyOnThisDay: j.DailyOnThisDay,
+		TrashRetention: j.TrashRetention,
+	}
+	if j.TagAliases != nil {
+		settings.TagAliases = make(map[string]string)
+		for k, v := range j.TagAliases {
+			settings.TagAliases[k] = v
+		}
+	}
+	if j.FieldSchema != nil {
+		settings.FieldSchema = make(FieldSchema)
+		for k, v := range j.FieldSchema {
+			settings.FieldSchema[k] = v
+		}
+	}
+	if j.Lookbacks != nil {
+		lookbacks := append([]string{}, *j.Lookbacks...)
+		settings.Lookbacks = &lookbacks
+	}
+	return settings
+}
+
+// returns a copy of the state of the journal, compared with the state
+// after the command to find what it changed
+func (j *Journal) snapshot() (state journalState) {
+	state = journalState{
+		entries:  make([]Entry, len(j.Entries)),
+		trash:    append([]TrashedEntry(nil), j.Trash...),
+		history:  make(map[string][]Revision),
+		settings: j.undoSettings(),
+	}
+	for i, entry := range j.Entries {
+		state.entries[i] = copyEntry(entry)
+	}
+	// revisions are only appended, so the lists can be shared
+	for id, revisions := range j.History {
+		state.history[id] = revisions
+	}
+	return state
+}
+
+// returns what changed in the entries, in the trash and in the settings since
+// the snapshot was taken. If nothing changed, changed is false
+func (j *Journal) changesSince(before journalState, operation string) (state UndoState, changed bool) {
+	state = UndoState{Operation: operation, Time: time.Now().Format(time.RFC3339)}
+
+	current := make(map[string]Entry)
+	for _, entry := range j.Entries {
+		current[entry.ID] = entry
+	}
+	existed := make(map[string]bool)
+	for _, entry := range before.entries {
+		existed[entry.ID] = true
+		if now, found := current[entry.ID]; !found || !sameEntry(entry, now) {
+			state.Entries = append(state.Entries, entry)
+		}
+	}
+	for _, entry := range j.Entries {
+		if !existed[entry.ID] {
+			state.Added = append(state.Added, entry.ID)
+		}
+	}
+
+	trashed := make(map[string]bool)
+	for _, t := range j.Trash {
+		trashed[t.Entry.ID] = true
+	}
+	wasTrashed := make(map[string]bool)
+	for _, t := range before.trash {
+		wasTrashed[t.Entry.ID] = true
+		if !trashed[t.Entry.ID] {
+			state.Trash = append(state.Trash, t)
+		}
+	}
+	for _, t := range j.Trash {
+		if !wasTrashed[t.Entry.ID] {
+			state.Trashed = append(state.Trashed, t.Entry.ID)
+		}
+	}
+
+	changed = len(state.Entries) > 0 || len(state.Added) > 0 || len(state.Trash) > 0 || len(state.Trashed) > 0 ||
+		!reflect.DeepEqual(before.settings, j.undoSettings())
+	state.Settings = before.settings
+	return state, changed
+}
+
+// keep the previous versions of the entries touched by a change, as they
+// were before it, so that the revisions recorded by the change are undone too
+func (state *UndoState) keepHistory(before journalState) {
+	var ids []string
+	for _, entry := range state.Entries {
+		ids = append(ids, entry.ID)
+	}
+	for _, t := range state.Trash {
+		ids = append(ids, t.Entry.ID)
+	}
+	ids = append(append(ids, state.Added...), state.Trashed...)
+
+	state.History = make(map[string][]Revision)
+	for _, id := range ids {
+		state.History[id] = before.history[id]
+	}
+}
+
+// restore the state of the journal before the last change
+func (j *Journal) undo() (operation string, e error) {
+	if j.Undo == nil {
+		return "", errors.New("there's nothing to undo")
+	}
+	state := j.Undo
+
+	// entries
+	removed := make(map[string]bool)
+	for _, id := range state.Added {
+		removed[id] = true
+	}
+	for _, entry := range state.Entries {
+		removed[entry.ID] = true
+	}
+	entries := make([]Entry, 0)
+	for _, entry := range j.Entries {
+		if !removed[entry.ID] {
+			entries = append(entries, entry)
+		}
+	}
+	j.Entries = append(entries, state.Entries...)
+
+	// trash
+	trashed := make(map[string]bool)
+	for _, id := range state.Trashed {
+		trashed[id] = true
+	}
+	trash := make([]TrashedEntry, 0)
+	for _, t := range j.Trash {
+		if !trashed[t.Entry.ID] {
+			trash = append(trash, t)
+		}
+	}
+	j.Trash = append(trash, state.Trash...)
+
+	// history
+	for id, revisions := range state.History {
+		if len(revisions) == 0 {
+			delete(j.History, id)
+		} else if j.History != nil {
+			j.History[id] = revisions
+		} else {
+			j.History = map[string][]Revision{id: revisions}
+		}
+	}
+
+	// settings
+	j.TagAliases = state.Settings.TagAliases
+	j.FieldSchema = state.Settings.FieldSchema
+	j.DailyOnThisDay = state.Settings.DailyOnThisDay
+	j.Lookbacks = state.Settings.Lookbacks
+	j.TrashRetention = state.Settings.TrashRetention
+
+	j.parseEntriesTime()
+	sort.Slice(j.Entries, func(i, k int) bool { return j.Entries[i].timeObj.Before(j.Entries[k].timeObj) })
+	// only the last change can be undone
+	j.Undo = nil
+
+	return state.Operation, nil
+}
+
+// calculate the time of each entry, in the journal and in the trash
+func (j *Journal) parseEntriesTime() {
+	for i := 0; i < len(j.Entries); i++ {
+		j.Entries[i].timeObj, _ = time.Parse(j.timeFormat, j.Entries[i].Timestamp)
+	}
+	for i := 0; i < len(j.Trash); i++ {
+		j.Trash[i].Entry.timeObj, _ = time.Parse(j.timeFormat, j.Trash[i].Entry.Timestamp)
+	}
+}
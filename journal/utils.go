@@ -1,10 +1,15 @@
 package main
 
 import (
+	"bufio"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"os"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -34,6 +39,28 @@ func getPassword(prompt string) (password string, e error) {
 	return string(bytepw), e
 }
 
+// reader of the standard input, shared by all the questions
+var stdin = bufio.NewReader(os.Stdin)
+
+// asks a question until one of the choices is answered.
+// Returns an empty string if the input ends
+func askChoice(prompt string, choices []string) string {
+	for {
+		fmt.Print(prompt, " ")
+		answer, e := stdin.ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		for _, c := range choices {
+			if answer == c {
+				return c
+			}
+		}
+		if e != nil {
+			fmt.Println()
+			return ""
+		}
+	}
+}
+
 // finds the first matching delimiter in list
 func findDelimiter(entry string, delimiters []string) string {
 	for _, e := range entry {
@@ -60,8 +87,13 @@ func parseEntry(entry string) (string, time.Time) {
 	parsedDay, level := parseDay(entry)
 	if level == 0 {
 		words := strings.Split(entry, " ")
+		// the time can follow "at" (today at noon)
+		if strings.EqualFold(words[1], "at") {
+			return strings.Join(words[3:], " "), parsedDay
+		}
 		return strings.Join(words[2:], " "), parsedDay
-	} else if level == 1 {
+	} else if level == 1 || level == 2 {
+		// a month (YYYY-MM) is the first day of the month
 		words := strings.Split(entry, " ")
 		return strings.Join(words[1:], " "), parsedDay
 	} else {
@@ -72,8 +104,7 @@ func parseEntry(entry string) (string, time.Time) {
 // loads day from string
 // resoulution: level 0 -> minute, level 1 -> day, level 2 -> month, level 3 -> year, level -1 -> undefined
 func parseDay(entry string) (timeObj time.Time, level int) {
-	var dateObj, hourObj time.Time
-	var hourErr error
+	var dateObj time.Time
 
 	words := strings.Split(entry, " ")
 
@@ -81,7 +112,7 @@ func parseDay(entry string) (timeObj time.Time, level int) {
 	firstWord := strings.Split(entry, " ")[0]
 
 	dateObj, level = func(date_str string) (time.Time, int) {
-		switch date_str {
+		switch strings.ToLower(date_str) {
 		case "yesterday":
 			// the first word was yesterday. Return today's date MINUS one day
 			return time.Now().AddDate(0, 0, -1), 1
@@ -89,8 +120,6 @@ func parseDay(entry string) (timeObj time.Time, level int) {
 			// the first word was today. Return today's date
 			return time.Now(), 1
 		default:
-			// check the second word, it might be time
-
 			// the first word wasn't either yesterday or today.
 			// try to parse the date. If it work, remove the first word.
 			// If it doesn't work, the date is today (the first word
@@ -100,7 +129,8 @@ func parseDay(entry string) (timeObj time.Time, level int) {
 			for level, template := range timeTemplates {
 				timeObj, e := time.Parse(template, firstWord)
 				if e == nil {
-					return timeObj, level
+					// templates start from the day resolution
+					return timeObj, level + 1
 				}
 			}
 
@@ -118,21 +148,17 @@ func parseDay(entry string) (timeObj time.Time, level int) {
 		}
 	}(firstWord)
 
-	// if there's a second word, check if it contains the hour
-	if len(words) > 1 {
-		secondWord := words[1]
-
-		hourObj, hourErr = func(hour_str string) (time.Time, error) {
-			timeObj, e := time.Parse("15.04", hour_str)
-			if e == nil {
-				return timeObj, e
-			}
-			return time.Time{}, e
-		}(secondWord)
-
-		// if no error has been found, create the new date with the correct hour
-		if hourErr == nil && level == 0 {
-			newDate := time.Date(dateObj.Year(), dateObj.Month(), dateObj.Day(), hourObj.Hour(), hourObj.Minute(), 0, 0, dateObj.Location())
+	// if the first word was a day and there's a second word,
+	// check if it contains the hour. Named times need "at" before
+	// them (today at morning), since they might start the title
+	if level == 1 && len(words) > 1 {
+		hour, minute, ok := parseTime(words[1], false)
+		if strings.EqualFold(words[1], "at") && len(words) > 2 {
+			hour, minute, ok = parseTime(words[2], true)
+		}
+		// if the hour was recognized, create the new date with the correct hour
+		if ok {
+			newDate := time.Date(dateObj.Year(), dateObj.Month(), dateObj.Day(), hour, minute, 0, 0, dateObj.Location())
 			return newDate, 0
 		}
 	}
@@ -140,6 +166,42 @@ func parseDay(entry string) (timeObj time.Time, level int) {
 	return dateObj, level
 }
 
+// check if a date contains the time, down to the minute
+func isMinute(entry string) bool {
+	_, level := parseDay(entry)
+	return level == 0
+}
+
+// loads time of the day from string
+// accepted formats: 15:04, 15.04, 3pm, 3:04pm, 3.04pm and, if named
+// is true, noon, midnight and the buckets morning, afternoon, evening, night
+func parseTime(word string, named bool) (hour, minute int, ok bool) {
+	word = strings.ToLower(word)
+
+	// named times of the day
+	buckets := map[string]int{
+		"midnight":  0,
+		"morning":   9,
+		"noon":      12,
+		"afternoon": 15,
+		"evening":   19,
+		"night":     22,
+	}
+	if hour, ok := buckets[word]; ok && named {
+		return hour, 0, true
+	}
+
+	timeTemplates := [...]string{"15:04", "15.04", "3pm", "3:04pm", "3.04pm"}
+	for _, template := range timeTemplates {
+		timeObj, e := time.Parse(template, word)
+		if e == nil {
+			return timeObj.Hour(), timeObj.Minute(), true
+		}
+	}
+
+	return 0, 0, false
+}
+
 // check if two dates are matching down to the minute
 func sameMinute(date1, date2 time.Time) bool {
 	return date1.Format("20060102-1504") == date2.Format("20060102-1504")
@@ -150,6 +212,11 @@ func sameDay(date1, date2 time.Time) bool {
 	return date1.Format("20060102") == date2.Format("20060102")
 }
 
+// check if two dates are on the same calendar day, regardless of the year
+func sameDayOfYear(date1, date2 time.Time) bool {
+	return date1.Format("0102") == date2.Format("0102")
+}
+
 // check if two dates are matching down to the month
 func sameMonth(date1, date2 time.Time) bool {
 	return date1.Format("200601") == date2.Format("200601")
@@ -160,6 +227,57 @@ func sameYear(date1, date2 time.Time) bool {
 	return date1.Format("2006") == date2.Format("2006")
 }
 
+// returns the start of the day, week (starting on monday), month or year
+// containing a date
+func periodStart(date time.Time, period string) (start time.Time, e error) {
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case "day":
+		return day, nil
+	case "week":
+		// days since monday
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset), nil
+	case "month":
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+	case "year":
+		return time.Date(day.Year(), 1, 1, 0, 0, 0, 0, time.UTC), nil
+	}
+	return time.Time{}, errors.New("unknown period '" + period + "'. Periods: day, week, month, year")
+}
+
+// returns the start of the period following the one starting at start
+func nextPeriod(start time.Time, period string) time.Time {
+	switch period {
+	case "week":
+		return start.AddDate(0, 0, 7)
+	case "month":
+		return start.AddDate(0, 1, 0)
+	case "year":
+		return start.AddDate(1, 0, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// returns the name of the period starting at start (e.g. 2021-W07)
+func periodLabel(start time.Time, period string) string {
+	switch period {
+	case "week":
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case "month":
+		return start.Format("2006-01")
+	case "year":
+		return start.Format("2006")
+	}
+	return start.Format("2006-01-02")
+}
+
+// formats a number with at most two decimals
+func formatNumber(number float64) string {
+	return strconv.FormatFloat(math.Round(number*100)/100, 'f', -1, 64)
+}
+
 // check if a date is between two other dates
 func dateBetween(current, start, end time.Time) bool {
 	return current.After(start) && current.Before(end)
@@ -193,59 +311,537 @@ func printEntries(entries []Entry, printPlaintext bool, printJSON bool) {
 		fmt.Println(string(JSONBytes))
 	} else {
 		for _, entry := range entries {
-			fmt.Println()
-			// print timestamp
-			fmt.Print(colorize.BrightBlue("Date: "))
-			fmt.Print(entry.Timestamp, "\n")
+			printColoredEntry(entry, nil)
+		}
+		fmt.Println()
+	}
+}
 
-			// print title
-			fmt.Print(colorize.BrightGreen("Title: "))
-			fmt.Print(entry.Title, "\n")
+// print a single entry with colors
+// if a matcher is provided, the matches are highlighted
+func printColoredEntry(entry Entry, matcher textMatcher) {
+	fmt.Println()
+	// print timestamp
+	fmt.Print(brightBlue("Date: "))
+	fmt.Print(entry.Timestamp, "\n")
+	if entry.Updated != "" {
+		fmt.Print(brightBlue("Updated: "))
+		fmt.Print(entry.Updated, "\n")
+	}
 
-			// print content
-			fmt.Print(colorize.BrightGreen("Content: "))
-			fmt.Print(entry.Content, "\n")
+	// print title
+	fmt.Print(brightGreen("Title: "))
+	fmt.Print(highlightMatches(entry.Title, matcher), "\n")
 
-			// print tags
-			fmt.Print(colorize.BrightMagenta("Tags: "))
-			if len(entry.Tags) > 0 {
-				fmt.Print("+" + strings.Join(entry.Tags, " +"))
-			}
-			fmt.Println()
+	// print content
+	fmt.Print(brightGreen("Content: "))
+	fmt.Print(highlightMatches(entry.Content, matcher), "\n")
 
-			// print fields
-			fmt.Print(colorize.BrightGreen("Fields: "))
-			for k, v := range entry.Fields {
-				fmt.Print(k, "=", v, " ")
-			}
+	// print tags
+	fmt.Print(brightMagenta("Tags: "))
+	if len(entry.Tags) > 0 {
+		fmt.Print("+" + strings.Join(entry.Tags, " +"))
+	}
+	fmt.Println()
 
-			// add some spacing
-			fmt.Println()
+	// print fields
+	fmt.Print(brightGreen("Fields: "))
+	for k, v := range entry.Fields {
+		fmt.Print(highlightMatches(k, matcher), "=", highlightMatches(v, matcher), " ")
+	}
+
+	// add some spacing
+	fmt.Println()
+}
+
+// check if the output is shown in a terminal
+func isTerminal() bool {
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// check if the output can be colored (see no-color.org)
+func useColors() bool {
+	return os.Getenv("NO_COLOR") == ""
+}
+
+// color a text, unless colors are disabled
+func colorText(text string, styles ...colorize.Style) string {
+	if !useColors() {
+		return text
+	}
+	var colors []interface{}
+	for _, s := range styles {
+		colors = append(colors, s)
+	}
+	return colorize.StyleText(text, colors...)
+}
+
+// colored texts, unless colors are disabled. As in the colorize
+// package, the arguments are joined by spaces
+func brightRed(text ...interface{}) string {
+	return colorText(joinText(text), colorize.FgBrightRed)
+}
+
+func brightGreen(text ...interface{}) string {
+	return colorText(joinText(text), colorize.FgBrightGreen)
+}
+
+func brightYellow(text ...interface{}) string {
+	return colorText(joinText(text), colorize.FgBrightYellow)
+}
+
+func brightBlue(text ...interface{}) string {
+	return colorText(joinText(text), colorize.FgBrightBlue)
+}
+
+func brightMagenta(text ...interface{}) string {
+	return colorText(joinText(text), colorize.FgBrightMagenta)
+}
+
+// joins the arguments of a colored text
+func joinText(text []interface{}) string {
+	joined := fmt.Sprint(text)
+	return joined[1 : len(joined)-1]
+}
+
+// set the style of the following output, unless colors are disabled
+func setStyle(styles ...colorize.Style) {
+	if useColors() {
+		colorize.SetStyle(styles...)
+	}
+}
+
+// reset the style of the following output
+func resetStyle() {
+	if useColors() {
+		colorize.ResetStyle()
+	}
+}
+
+// highlight a word
+func highlight(word string) string {
+	return colorText(word, colorize.FgBrightYellow, colorize.Bold)
+}
+
+// highlight all the matches in a text
+func highlightMatches(text string, matcher textMatcher) string {
+	if matcher == nil {
+		return text
+	}
+
+	var b strings.Builder
+	position := 0
+	for _, m := range matcher(text) {
+		b.WriteString(text[position:m[0]])
+		b.WriteString(highlight(text[m[0]:m[1]]))
+		position = m[1]
+	}
+	b.WriteString(text[position:])
+	return b.String()
+}
+
+// print entries, highlighting the matches in the colored view
+func printMatchingEntries(entries []Entry, matcher textMatcher, printPlaintext bool, printJSON bool) {
+	if printPlaintext || printJSON {
+		printEntries(entries, printPlaintext, printJSON)
+		return
+	}
+
+	for _, entry := range entries {
+		printColoredEntry(entry, matcher)
+	}
+	fmt.Println()
+}
+
+// print search results, best first, with a snippet around the match
+func printSearchResults(results []searchResult, keywords []string, printPlaintext bool, printJSON bool) {
+	if printPlaintext || printJSON {
+		entries := make([]Entry, 0)
+		for _, r := range results {
+			entries = append(entries, r.entry)
 		}
-		fmt.Println()
+		printEntries(entries, printPlaintext, printJSON)
+		return
+	}
+
+	terms := searchTerms(keywords)
+	for _, r := range results {
+		printColoredEntry(r.entry, nil)
+		// print snippet
+		fmt.Print(brightYellow("Match: "))
+		fmt.Print(makeSnippet(r.entry, terms, highlight), "\n")
 	}
+	fmt.Println()
 }
 
-// print tags (strings starting with + in entry)
+// print tags (strings starting with + in entry) as a tree
 func printTags(tags map[string]int) {
-	for k, v := range tags {
+	var sorted []string
+	for k := range tags {
+		sorted = append(sorted, k)
+	}
+	sort.Slice(sorted, func(i, k int) bool { return tagLess(sorted[i], sorted[k]) })
+
+	for _, k := range sorted {
+		levels := strings.Split(k, tagSeparator)
+		// indent children under their parent
+		fmt.Print(strings.Repeat("  ", len(levels)-1))
 		// print key
-		fmt.Print(colorize.BrightMagenta(k, " "))
+		fmt.Print(brightMagenta(levels[len(levels)-1], " "))
 		// print value
-		fmt.Print(v, "\n")
+		fmt.Print(tags[k], "\n")
 	}
 }
 
-// print fields (strings starting with @ in entry)
-func printFields(fields []map[string]string) {
-	for _, field := range fields {
-		for k, v := range field {
-			// print key
-			fmt.Print(colorize.BrightMagenta(k, " "))
-			// print value
-			fmt.Print(v, "\n")
+// print the entries affected by a change of tags
+// returns true if the change should be applied
+func printTagPreview(entries []Entry, dryRun bool) bool {
+	if len(entries) == 0 {
+		printError(errors.New("tag not found"), 1)
+		return false
+	}
+
+	fmt.Println(brightYellow(fmt.Sprint(len(entries), " entries will be changed:")))
+	printEntries(entries, true, false)
+
+	if dryRun {
+		fmt.Println(brightYellow("Dry run, nothing has been changed"))
+		return false
+	}
+	return true
+}
+
+// print tag aliases and the tags replacing them
+func printTagAliases(aliases map[string]string) {
+	var sorted []string
+	for k := range aliases {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	for _, k := range sorted {
+		// print alias
+		fmt.Print(brightMagenta("+"+k, " "))
+		// print tag
+		fmt.Print("-> +", aliases[k], "\n")
+	}
+}
+
+// print fields (strings starting with @ in entry) and their values
+func printFields(fields []fieldSummary) {
+	// number of values shown for each field
+	const shownValues = 10
+
+	for _, f := range fields {
+		// print key
+		fmt.Print(brightMagenta("@"+f.key, " "))
+		fmt.Print(f.count, " entries, ", len(f.values), " distinct values\n")
+
+		// print statistics
+		if f.numeric {
+			fmt.Print("  ", brightGreen("min "), formatNumber(f.min))
+			fmt.Print(brightGreen(" max "), formatNumber(f.max))
+			fmt.Print(brightGreen(" sum "), formatNumber(f.sum))
+			fmt.Print(brightGreen(" mean "), formatNumber(f.mean), "\n")
+		}
+
+		// print values, most used first
+		fmt.Print("  ")
+		for i, v := range f.sortedValues() {
+			if i == shownValues {
+				fmt.Print("and ", len(f.values)-shownValues, " more")
+				break
+			}
+			fmt.Print(v, " (", f.values[v], ") ")
+		}
+		fmt.Println()
+	}
+}
+
+// print a field aggregated over periods
+func printFieldPeriods(key string, periods []fieldPeriod) {
+	fmt.Println(brightMagenta("@" + key))
+	for _, p := range periods {
+		// print period
+		fmt.Print(brightBlue(p.label, " "))
+		fmt.Print(p.count, " entries")
+		// print statistics
+		if p.numbers > 0 {
+			fmt.Print(brightGreen(" min "), formatNumber(p.min))
+			fmt.Print(brightGreen(" max "), formatNumber(p.max))
+			fmt.Print(brightGreen(" sum "), formatNumber(p.sum))
+			fmt.Print(brightGreen(" mean "), formatNumber(p.mean()))
+		}
+		fmt.Println()
+	}
+}
+
+// print a ranking of items with their count
+func printRanking(title string, ranking []rankedItem) {
+	fmt.Print(brightGreen(title + " "))
+	for _, r := range ranking {
+		fmt.Print(r.name, " (", r.count, ") ")
+	}
+	fmt.Println()
+}
+
+// print a streak of days
+func printStreak(title string, s streak) {
+	fmt.Print(brightGreen(title + " "))
+	if s.days == 0 {
+		fmt.Print("none\n")
+		return
+	}
+	fmt.Print(s.days, " days (", s.start.Format("2006-01-02"), " to ", s.end.Format("2006-01-02"), ")\n")
+}
+
+// print statistics about the entries
+func printStats(stats journalStats) {
+	fmt.Println()
+	// print entries
+	fmt.Print(brightBlue("Entries: "))
+	fmt.Print(stats.entries, " from ", stats.first.Format("2006-01-02"), " to ", stats.last.Format("2006-01-02"), "\n")
+	fmt.Print(brightGreen("Days with entries: "))
+	fmt.Print(stats.days, " out of ", stats.spanDays, "\n")
+
+	// print averages
+	fmt.Print(brightGreen("Entries per day: "))
+	fmt.Print(formatNumber(float64(stats.entries)/float64(stats.spanDays)), "\n")
+	fmt.Print(brightGreen("Entries per week: "))
+	fmt.Print(formatNumber(float64(stats.entries)/float64(stats.spanWeeks)), " (", stats.weeks, " weeks with entries out of ", stats.spanWeeks, ")\n")
+	fmt.Print(brightGreen("Entries per month: "))
+	fmt.Print(formatNumber(float64(stats.entries)/float64(stats.spanMonths)), " (", stats.months, " months with entries out of ", stats.spanMonths, ")\n")
+
+	// print streaks
+	printStreak("Current streak:", stats.current)
+	printStreak("Longest streak:", stats.longest)
+
+	// print words
+	fmt.Print(brightGreen("Words: "))
+	fmt.Print(stats.words, " (", formatNumber(float64(stats.words)/float64(stats.entries)), " per entry)\n")
+	fmt.Print(brightGreen("Longest entry: "))
+	fmt.Print(stats.longestEntry.Title, " [", stats.longestEntry.Timestamp, "] ", stats.longestEntryWords, " words\n")
+
+	// print rankings
+	printRanking("Most used tags:", stats.tags)
+	printRanking("Most used fields:", stats.fields)
+	printRanking("Busiest weekdays:", stats.weekdays)
+	printRanking("Busiest hours:", stats.hours)
+	fmt.Println()
+}
+
+// print a review of a period, compared with the previous one
+func printReview(review periodReview, printMarkdown bool, printJSON bool) {
+	if printJSON {
+		JSONBytes, _ := json.MarshalIndent(review, "", "  ")
+		fmt.Println(string(JSONBytes))
+		return
+	} else if printMarkdown {
+		fmt.Print(review.markdown())
+		return
+	}
+
+	// print header
+	fmt.Print("\n", brightYellow(fmt.Sprint("Review of ", review.Label, " (", review.Start, " to ", review.End, ")")), "\n")
+
+	// print summary, compared with the previous period
+	fmt.Print(brightBlue("Entries: "))
+	fmt.Print(len(review.Entries), " (", formatDelta(float64(len(review.Entries)), float64(review.Previous.Entries)), " from ", review.Previous.Label, ")\n")
+	fmt.Print(brightGreen("Words: "))
+	fmt.Print(review.Words, " (", formatDelta(float64(review.Words), float64(review.Previous.Words)), ")\n")
+	fmt.Print(brightGreen("Days with entries: "))
+	fmt.Print(review.Days, " out of ", review.TotalDays, " (", formatDelta(float64(review.Days), float64(review.Previous.Days)), ")\n")
+
+	// print tags
+	if len(review.Tags) > 0 {
+		fmt.Print(brightGreen("Tags: "))
+		for _, t := range review.Tags {
+			fmt.Print(brightYellow("+"+t.Tag), " ", t.Entries, " (", formatDelta(float64(t.Entries), float64(t.Previous)), ") ")
+		}
+		fmt.Println()
+	}
+
+	// print fields
+	for _, f := range review.Fields {
+		fmt.Print(brightMagenta("@" + f.Key + " "))
+		fmt.Print(f.Entries, " entries")
+		if f.Numeric {
+			fmt.Print(brightGreen(" min "), formatNumber(f.Min))
+			fmt.Print(brightGreen(" max "), formatNumber(f.Max))
+			fmt.Print(brightGreen(" sum "), formatNumber(f.Sum))
+			fmt.Print(brightGreen(" mean "), formatNumber(f.Mean))
+			if f.PreviousMean != nil {
+				fmt.Print(" (", formatDelta(f.Mean, *f.PreviousMean), ")")
+			}
+		}
+		fmt.Println()
+	}
+
+	// print longest entries
+	fmt.Print(brightGreen("Longest entries: "), "\n")
+	for _, entry := range review.Longest {
+		fmt.Print("  ", entry.Title, " [", entry.Timestamp, "] ", entry.Words, " words\n")
+	}
+
+	// print days without entries
+	if len(review.EmptyDays) > 0 {
+		fmt.Print(brightGreen("Days without entries: "))
+		fmt.Print(strings.Join(review.EmptyDays, " "), "\n")
+	}
+
+	// print entries
+	printEntries(review.Entries, false, false)
+}
+
+// print the entries written on some days in the past
+func printRetrospective(groups []retrospectiveGroup, printPlaintext bool, printJSON bool) {
+	if printJSON {
+		entries := make([]Entry, 0)
+		for _, g := range groups {
+			entries = append(entries, g.entries...)
+		}
+		printEntries(entries, printPlaintext, printJSON)
+		return
+	}
+
+	for _, g := range groups {
+		// print label
+		fmt.Print("\n", brightYellow(fmt.Sprint(g.label, " (", g.date.Format("Monday 2006-01-02"), ")")))
+		if printPlaintext {
+			fmt.Println()
+		}
+		printEntries(g.entries, printPlaintext, printJSON)
+	}
+}
+
+// print the result of a merge
+func printMergeReport(report mergeReport, dryRun bool) {
+	if dryRun {
+		fmt.Println(brightYellow("Dry run, nothing was merged"))
+	}
+
+	fmt.Print(brightGreen("Entries added: "), len(report.added), "\n")
+	for _, entry := range report.added {
+		fmt.Print("  [", entry.Timestamp, "] ", entry.Title, "\n")
+	}
+	fmt.Print(brightGreen("Entries already in the journal: "), report.duplicates, "\n")
+
+	fmt.Print(brightGreen("Conflicts: "), len(report.conflicts), "\n")
+	for _, c := range report.conflicts {
+		fmt.Print("  [", c.current.Timestamp, "] ", c.current.Title)
+		switch c.resolution {
+		case "current":
+			fmt.Print(" -> kept the current version\n")
+		case "other":
+			fmt.Print(" -> kept the other version\n")
+		case "":
+			fmt.Print(" -> will be asked\n")
+		default:
+			fmt.Print(" -> kept both versions\n")
+		}
+	}
+}
+
+// asks for confirmation before removing more than one entry
+func confirmRemoval(count int, yes bool) bool {
+	if yes || count <= 1 {
+		return true
+	}
+	return askChoice(fmt.Sprint("Remove ", count, " entries? [y]es/[n]o"), []string{"y", "n"}) == "y"
+}
+
+// asks which of the entries written in the same minute to use.
+// Returns the chosen entries, none if the question is not answered
+func chooseEntry(entries []Entry) (chosen []Entry) {
+	fmt.Println(brightYellow(fmt.Sprint(len(entries), " entries were written in the same minute:")))
+	choices := []string{"a", "n"}
+	for i, entry := range entries {
+		fmt.Print("  [", i+1, "] ", entry.Title, " (", entry.ID, ")\n")
+		choices = append(choices, strconv.Itoa(i+1))
+	}
+
+	switch answer := askChoice("Which one? Type its number, [a]ll or [n]one", choices); answer {
+	case "a":
+		return entries
+	case "n", "":
+		return nil
+	default:
+		n, _ := strconv.Atoi(answer)
+		return []Entry{entries[n-1]}
+	}
+}
+
+// print the entries in the trash with their ID and the days left before
+// they are removed permanently
+func printTrash(trash []TrashedEntry, retention int, printJSON bool) {
+	if printJSON {
+		JSONBytes, _ := json.MarshalIndent(trash, "", "  ")
+		fmt.Println(string(JSONBytes))
+		return
+	}
+
+	for _, t := range trash {
+		fmt.Print(brightBlue(fmt.Sprint("[", t.Entry.Timestamp, "] ")))
+		fmt.Print(t.Entry.Title, " ", brightMagenta(fmt.Sprint("(", t.Entry.ID, ")")))
+		if removed, e := time.Parse(time.RFC3339, t.Removed); e == nil {
+			left := int(time.Until(removed.AddDate(0, 0, retention)).Hours()/24) + 1
+			fmt.Print(" ", left, " days left")
+		}
+		fmt.Println()
+	}
+	fmt.Print(len(trash), " entries in the trash\n")
+}
+
+// print the previous versions of an entry, each one with the changes
+// that replaced it
+func printHistory(entry Entry, revisions []Revision, printJSON bool) {
+	if printJSON {
+		JSONBytes, _ := json.MarshalIndent(revisions, "", "  ")
+		fmt.Println(string(JSONBytes))
+		return
+	}
+
+	current := entryRevision(entry)
+	for i, r := range revisions {
+		next := current
+		if i+1 < len(revisions) {
+			next = revisions[i+1]
+		}
+
+		fmt.Print(brightBlue(fmt.Sprint("\nRevision ", i+1)), " ", r.Title, "\n")
+		fmt.Print("Replaced on ", r.Time, " by: journal ", r.Operation, "\n")
+		for _, d := range diffRevisions(r, next) {
+			fmt.Print(brightGreen(strings.ToUpper(d.attribute[:1])+d.attribute[1:]+":"), "\n")
+			for _, line := range d.lines {
+				switch line[0] {
+				case '-':
+					line = brightRed(line)
+				case '+':
+					line = brightGreen(line)
+				}
+				fmt.Print("  ", line, "\n")
+			}
 		}
 	}
+	fmt.Print("\n", len(revisions), " revisions. Use --revert ", entry.ID, " <number> to restore one\n")
+}
+
+// print the field schema as JSON
+func printFieldSchema(schema FieldSchema) {
+	JSONBytes, _ := json.MarshalIndent(schema, "", "  ")
+	fmt.Println(string(JSONBytes))
+}
+
+// print the fields that don't follow the schema
+func printFieldViolations(violations []fieldViolation) {
+	for _, v := range violations {
+		// print entry
+		fmt.Print(brightBlue(fmt.Sprint("[", v.entry.Timestamp, "] ")))
+		fmt.Print(v.entry.Title, " ")
+		// print problem
+		fmt.Print(brightRed(v.problem), "\n")
+	}
+	fmt.Print(len(violations), " problems found\n")
 }
 
 // print error
@@ -253,41 +849,70 @@ func printFields(fields []map[string]string) {
 func printError(e error, level int8) {
 	switch level {
 	case 0:
-		colorize.SetStyle(colorize.FgBrightGreen)
+		setStyle(colorize.FgBrightGreen)
 	case 1:
-		colorize.SetStyle(colorize.FgBrightYellow)
+		setStyle(colorize.FgBrightYellow)
 	case 2:
-		colorize.SetStyle(colorize.FgBrightRed)
+		setStyle(colorize.FgBrightRed)
 	case 3:
-		colorize.SetStyle(colorize.BgBrightRed, colorize.FgBrightWhite)
+		setStyle(colorize.BgBrightRed, colorize.FgBrightWhite)
 	}
 	fmt.Print(e, "\n")
-	colorize.ResetStyle()
+	resetStyle()
 }
 
 // print current version
 func printVersion(repo, version string) {
-	fmt.Print(colorize.BrightGreen("\nJournal Version: "))
-	fmt.Print(colorize.BrightBlue(version, "\n"))
-	colorize.SetStyle(colorize.FgBrightGreen)
-	fmt.Print(colorize.BrightGreen("GitHub repo: "))
-	fmt.Print(colorize.BrightBlue(repo, "\n"))
+	fmt.Print(brightGreen("\nJournal Version: "))
+	fmt.Print(brightBlue(version, "\n"))
+	setStyle(colorize.FgBrightGreen)
+	fmt.Print(brightGreen("GitHub repo: "))
+	fmt.Print(brightBlue(repo, "\n"))
 	return
 }
 
 // print update
 func printUpdate(repo, version, newestVersion string) {
 	if newestVersion == "" {
-		colorize.SetStyle(colorize.FgRed, colorize.Bold)
+		setStyle(colorize.FgRed, colorize.Bold)
 		fmt.Print("Cannot check if a new version is available.", "\n")
 	} else if version != newestVersion {
-		colorize.SetStyle(colorize.FgBrightRed, colorize.RapidBlink)
+		setStyle(colorize.FgBrightRed, colorize.RapidBlink)
 		fmt.Print("New version available: ")
 		fmt.Print(newestVersion, "\n")
 	} else {
-		colorize.SetStyle(colorize.FgBrightGreen)
+		setStyle(colorize.FgBrightGreen)
 		fmt.Print("You are running the most recent version", "\n")
 	}
 	fmt.Print("\n")
-	colorize.ResetStyle()
+	resetStyle()
+}
+
+// decimal number, with a dot or a comma as separator
+var decimalNumber = regexp.MustCompile(`^[+-]?\d+([.,]\d+)?`)
+
+// parses a decimal number. Infinities and NaN are not numbers here
+func parseDecimal(value string) (number float64, ok bool) {
+	if decimalNumber.FindString(value) != value {
+		return 0, false
+	}
+	number, e := strconv.ParseFloat(strings.ReplaceAll(value, ",", "."), 64)
+	if e != nil || math.IsInf(number, 0) || math.IsNaN(number) {
+		return 0, false
+	}
+	return number, true
+}
+
+// text after a number that makes it hexadecimal, binary, octal or exponential
+var numberNotation = regexp.MustCompile(`^([xXbBoO][0-9a-fA-F]|[eE][+-]?\d)`)
+
+// parses the number at the beginning of a string (e.g. 10 in 10km)
+func parseNumber(value string) (number float64, ok bool) {
+	value = strings.TrimSpace(value)
+	prefix := decimalNumber.FindString(value)
+	// 0x10 is not 0 followed by a unit
+	if numberNotation.MatchString(value[len(prefix):]) {
+		return 0, false
+	}
+	return parseDecimal(prefix)
 }
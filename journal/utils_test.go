@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		word         string
+		named        bool
+		hour, minute int
+		ok           bool
+	}{
+		{"7.30", false, 7, 30, true},
+		{"19:05", false, 19, 5, true},
+		{"7pm", false, 19, 0, true},
+		{"7AM", false, 7, 0, true},
+		{"7:30pm", false, 19, 30, true},
+		{"7.30pm", false, 19, 30, true},
+		{"noon", true, 12, 0, true},
+		{"Morning", true, 9, 0, true},
+		{"midnight", true, 0, 0, true},
+		{"night", true, 22, 0, true},
+		{"morning", false, 0, 0, false},
+		{"25:00", false, 0, 0, false},
+		{"run", true, 0, 0, false},
+		{"", false, 0, 0, false},
+	}
+
+	for _, test := range tests {
+		hour, minute, ok := parseTime(test.word, test.named)
+		if hour != test.hour || minute != test.minute || ok != test.ok {
+			t.Errorf("parseTime(%q, %v) = %d, %d, %v, want %d, %d, %v",
+				test.word, test.named, hour, minute, ok, test.hour, test.minute, test.ok)
+		}
+	}
+}
+
+func TestParseEntry(t *testing.T) {
+	tests := []struct {
+		entry, text, date string
+	}{
+		{"2021-03-01 run. great", "run. great", "2021-03-01 00:00"},
+		{"2021-03-01 7pm run. great", "run. great", "2021-03-01 19:00"},
+		{"2021-03-01 at 7.30 run", "run", "2021-03-01 07:30"},
+		{"2021-03-01 at morning run", "run", "2021-03-01 09:00"},
+		{"2021-03-01 morning run", "morning run", "2021-03-01 00:00"},
+		{"2021-03-01 at home", "at home", "2021-03-01 00:00"},
+		{"2021-03 trip", "trip", "2021-03-01 00:00"},
+	}
+
+	for _, test := range tests {
+		text, date := parseEntry(test.entry)
+		if text != test.text || date.Format("2006-01-02 15:04") != test.date {
+			t.Errorf("parseEntry(%q) = %q, %s, want %q, %s",
+				test.entry, text, date.Format("2006-01-02 15:04"), test.text, test.date)
+		}
+	}
+
+	// entries without a date are written now
+	if text, date := parseEntry("morning run"); text != "morning run" || time.Since(date) > time.Minute {
+		t.Errorf("parseEntry(%q) = %q, %v, want the whole text written now", "morning run", text, date)
+	}
+}